@@ -1,17 +1,61 @@
 package main
 
 import (
+	"context"
+	"flag"
+	"log"
 	"os"
+	"os/signal"
+	"syscall"
+	"time"
 
 	"github.com/subcommands_test/cli/lib"
 )
 
 func main() {
+	framed := flag.Bool("framed", false, "Exchange invocations and results as frames with IDs instead of plain lines")
+	maxInFlight := flag.Int("max-in-flight", 1, "Maximum number of invocations handled concurrently. Only used with -framed")
+	ordered := flag.Bool("ordered", false, "Handle invocations one after another in the order they were received")
+	multi := flag.Bool("multi", false, "Provide all commands of the lib package instead of hello only")
+	gracePeriod := flag.Duration("grace-period", 5*time.Second, "Time in-flight invocations have to finish on shutdown")
+	announce := flag.Duration("announce", 0, "Push an announcement event in this interval. Only used with -framed")
+
+	flag.Parse()
+
 	provider := &lib.ReaderWriterProvider{
 		Input:       os.Stdin,
 		Output:      os.Stdout,
+		Framed:      *framed,
+		MaxInFlight: *maxInFlight,
+		Ordered:     *ordered,
+		Manifest:    lib.NewManifest("cliprov", lib.Version, lib.HelloInfo),
 		HandlerFunc: lib.HelloProvider,
 	}
+	if *multi {
+		reg := lib.DefaultRegistry()
+		provider.ContextHandler = reg
+		provider.Manifest.Commands = reg.Describe()
+	}
+
+	sigs := make(chan os.Signal, 1)
+	signal.Notify(sigs, os.Interrupt, syscall.SIGINT, syscall.SIGTERM)
 
-	<-provider.Start()
+	done := provider.Start()
+	lib.AnnounceReady(os.Stderr)
+	if *framed && *announce > 0 {
+		go lib.Announce(context.Background(), *announce, "cliprov is still running", provider.Push)
+	}
+
+	select {
+	case <-done:
+		// Input closed and all invocations are answered
+	case sig := <-sigs:
+		log.Printf("Signal received: %v\n", sig)
+		ctx, cancel := context.WithTimeout(context.Background(), *gracePeriod)
+		defer cancel()
+		abandoned, err := provider.Shutdown(ctx)
+		if err != nil {
+			log.Printf("Grace period exceeded, abandoned %d invocations\n", abandoned)
+		}
+	}
 }
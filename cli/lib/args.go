@@ -0,0 +1,112 @@
+package lib
+
+import (
+	"strings"
+	"unicode"
+)
+
+var (
+	// ErrUnterminatedQuote is returned for lines with a quote which isn't closed.
+	ErrUnterminatedQuote = &Error{Code: CodeInvalidArgument, Message: "unterminated quote"}
+	// ErrTrailingBackslash is returned for lines ending with an unescaped backslash.
+	ErrTrailingBackslash = &Error{Code: CodeInvalidArgument, Message: "trailing backslash"}
+)
+
+// SplitArgs splits a line into arguments like a shell does. Arguments are
+// separated by any amount of whitespace. Single quotes keep everything
+// literally, double quotes keep everything but backslash escapes of '"'
+// and '\'. Outside of quotes a backslash escapes the following character.
+//
+//	!hello "Mary Ann"  it\'s  =>  [!hello, Mary Ann, it's]
+func SplitArgs(line string) ([]string, error) {
+	return SplitArgsN(line, -1)
+}
+
+// SplitArgsN splits a line like SplitArgs, but into n arguments at most.
+// The last argument is the raw rest of the line with surrounding
+// whitespace removed. If n is negative all arguments are split.
+func SplitArgsN(line string, n int) ([]string, error) {
+	args := []string{}
+	var current strings.Builder
+	inArg := false
+	var quote rune
+	escaped := false
+
+	for i, r := range line {
+		switch {
+		case escaped:
+			escaped = false
+			if quote == '"' && r != '"' && r != '\\' {
+				current.WriteRune('\\')
+			}
+			current.WriteRune(r)
+		case quote == '\'':
+			if r == '\'' {
+				quote = 0
+			} else {
+				current.WriteRune(r)
+			}
+		case quote == '"':
+			if r == '"' {
+				quote = 0
+			} else if r == '\\' {
+				escaped = true
+			} else {
+				current.WriteRune(r)
+			}
+		case unicode.IsSpace(r):
+			if inArg {
+				args = append(args, current.String())
+				current.Reset()
+				inArg = false
+			}
+		default:
+			if !inArg && n > 0 && len(args) == n-1 {
+				return append(args, strings.TrimSpace(line[i:])), nil
+			}
+			inArg = true
+			switch r {
+			case '\\':
+				escaped = true
+			case '\'', '"':
+				quote = r
+			default:
+				current.WriteRune(r)
+			}
+		}
+	}
+	if quote != 0 {
+		return nil, ErrUnterminatedQuote
+	}
+	if escaped {
+		return nil, ErrTrailingBackslash
+	}
+	if inArg {
+		args = append(args, current.String())
+	}
+	return args, nil
+}
+
+// JoinArgs joins the arguments to a line which SplitArgs splits into the
+// same arguments. Arguments are only quoted if necessary.
+// Line breaks are kept literally inside quotes, so arguments containing
+// them result in multiple lines.
+func JoinArgs(args []string) string {
+	quoted := make([]string, len(args))
+	for i, arg := range args {
+		quoted[i] = quoteArg(arg)
+	}
+	return strings.Join(quoted, " ")
+}
+
+func quoteArg(arg string) string {
+	if arg == "" {
+		return "''"
+	}
+	if strings.IndexFunc(arg, func(r rune) bool {
+		return unicode.IsSpace(r) || r == '\'' || r == '"' || r == '\\'
+	}) < 0 {
+		return arg
+	}
+	return "'" + strings.Replace(arg, "'", `'\''`, -1) + "'"
+}
@@ -0,0 +1,48 @@
+package lib
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestSplitArgs(t *testing.T) {
+	tests := []struct {
+		line     string
+		n        int
+		expected []string
+		err      error
+	}{
+		{line: "", n: -1, expected: []string{}},
+		{line: "Kevin", n: -1, expected: []string{"Kevin"}},
+		{line: "  !hello   Kevin  \n", n: -1, expected: []string{"!hello", "Kevin"}},
+		{line: `!hello "Mary Ann"`, n: -1, expected: []string{"!hello", "Mary Ann"}},
+		{line: `it\'s 'a "quote"' "\"b\" \n"`, n: -1, expected: []string{"it's", `a "quote"`, `"b" \n`}},
+		{line: `"" ''`, n: -1, expected: []string{"", ""}},
+		{line: `pre"mid dle"post`, n: -1, expected: []string{"premid dlepost"}},
+		{line: `!say   hello  "world" `, n: 2, expected: []string{"!say", `hello  "world"`}},
+		{line: `!say`, n: 2, expected: []string{"!say"}},
+		{line: `"Mary Ann`, n: -1, err: ErrUnterminatedQuote},
+		{line: `Kevin\`, n: -1, err: ErrTrailingBackslash},
+	}
+	for _, test := range tests {
+		args, err := SplitArgsN(test.line, test.n)
+		if err != test.err {
+			t.Errorf("%q: expected error %v, got %v", test.line, test.err, err)
+			continue
+		}
+		if err == nil && !reflect.DeepEqual(args, test.expected) {
+			t.Errorf("%q: expected %q, got %q", test.line, test.expected, args)
+		}
+	}
+}
+
+func TestJoinArgs(t *testing.T) {
+	args := []string{"Kevin", "Mary Ann", "it's", "", `back\slash "quoted"`, "Mary\nAnn\r"}
+	split, err := SplitArgs(JoinArgs(args))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(split, args) {
+		t.Errorf("expected %q, got %q", args, split)
+	}
+}
@@ -0,0 +1,62 @@
+package lib
+
+import (
+	"context"
+	"errors"
+	"sync"
+)
+
+// ErrNoChunks is returned by SendChunk if ctx doesn't accept chunks.
+var ErrNoChunks = errors.New("chunks aren't supported by the invocation")
+
+type chunksKey struct{}
+
+// WithChunks returns a copy of ctx passing all chunks sent with SendChunk
+// to send. Transports able to stream use it to forward chunks as soon as
+// they're sent.
+func WithChunks(ctx context.Context, send func(chunk *Response) error) context.Context {
+	return context.WithValue(ctx, chunksKey{}, send)
+}
+
+// SendChunk sends a part of the response before the command returns,
+// e.g. to report progress. The response returned by the command marks the
+// end and may be empty. Transports without streaming send the messages of
+// all chunks together with the returned response, see Collect.
+func SendChunk(ctx context.Context, chunk *Response) error {
+	send, ok := ctx.Value(chunksKey{}).(func(*Response) error)
+	if !ok {
+		return ErrNoChunks
+	}
+	return send(chunk)
+}
+
+// Append adds the messages of other after the messages of resp.
+func (resp *Response) Append(other *Response) {
+	resp.Messages = append(resp.Messages, other.Messages...)
+}
+
+// Collect adapts the command for transports without streaming. Chunks
+// sent by the command are collected and their messages are returned in
+// front of the messages of the returned response.
+func Collect(cmd RichCommand) RichCommand {
+	return RichCommandFunc(func(ctx context.Context, args []string) (*Response, error) {
+		var mu sync.Mutex
+		collected := &Response{}
+		ctx = WithChunks(ctx, func(chunk *Response) error {
+			mu.Lock()
+			defer mu.Unlock()
+			collected.Append(chunk)
+			return nil
+		})
+		resp, err := cmd.HandleRich(ctx, args)
+		if err != nil {
+			return nil, err
+		}
+		mu.Lock()
+		defer mu.Unlock()
+		if resp != nil {
+			collected.Append(resp)
+		}
+		return collected, nil
+	})
+}
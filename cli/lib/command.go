@@ -1,5 +1,7 @@
 package lib
 
+import "context"
+
 // Command handles a command invocation with its Handle method.
 type Command interface {
 	Handle(args []string) string
@@ -7,3 +9,60 @@ type Command interface {
 
 // CommandFunc represents a Command as function directly.
 type CommandFunc func(args []string) string
+
+// FallibleCommand handles a command invocation like Command but is able to
+// report failures. Returning an *Error tells the invoker why the command
+// refused to handle the invocation. Every other error is reported as
+// internal error.
+type FallibleCommand interface {
+	Handle(args []string) (string, error)
+}
+
+// FallibleCommandFunc represents a FallibleCommand as function directly.
+type FallibleCommandFunc func(args []string) (string, error)
+
+// Handle calls the function itself.
+func (fn FallibleCommandFunc) Handle(args []string) (string, error) {
+	return fn(args)
+}
+
+// Handle calls the function itself.
+func (fn CommandFunc) Handle(args []string) string {
+	return fn(args)
+}
+
+// ContextCommand handles a command invocation like FallibleCommand. The
+// context is canceled as soon as the result isn't needed anymore, e.g.
+// because the invoker canceled the invocation or its deadline passed.
+type ContextCommand interface {
+	Handle(ctx context.Context, args []string) (string, error)
+}
+
+// ContextCommandFunc represents a ContextCommand as function directly.
+type ContextCommandFunc func(ctx context.Context, args []string) (string, error)
+
+// Handle calls the function itself.
+func (fn ContextCommandFunc) Handle(ctx context.Context, args []string) (string, error) {
+	return fn(ctx, args)
+}
+
+// WithContext adapts a Command to a ContextCommand. The command isn't
+// interrupted by the context, but isn't started if it's already done.
+func WithContext(cmd Command) ContextCommand {
+	return ContextCommandFunc(func(ctx context.Context, args []string) (string, error) {
+		if err := ctx.Err(); err != nil {
+			return "", err
+		}
+		return cmd.Handle(args), nil
+	})
+}
+
+// FallibleWithContext adapts a FallibleCommand to a ContextCommand like WithContext.
+func FallibleWithContext(cmd FallibleCommand) ContextCommand {
+	return ContextCommandFunc(func(ctx context.Context, args []string) (string, error) {
+		if err := ctx.Err(); err != nil {
+			return "", err
+		}
+		return cmd.Handle(args)
+	})
+}
@@ -5,6 +5,20 @@ import (
 	"strings"
 )
 
+// EchoInfo describes EchoProvider.
+var EchoInfo = CommandInfo{
+	Name:        "echo",
+	Usage:       "echo [text...]",
+	Description: "Repeats the text",
+}
+
+// HelloInfo describes HelloProvider.
+var HelloInfo = CommandInfo{
+	Name:        "hello",
+	Usage:       "hello [name]",
+	Description: "Greets you or the given name",
+}
+
 // EchoProvider joins the arguments.
 func EchoProvider(args []string) string {
 	return strings.Join(args, " ")
@@ -17,3 +31,13 @@ func HelloProvider(args []string) string {
 	}
 	return fmt.Sprintf("Hello, %s!", args[0])
 }
+
+// DefaultRegistry returns a Registry with all commands of this package.
+// Invocations not starting with a command name are handled by HelloProvider.
+func DefaultRegistry() *Registry {
+	reg := NewRegistry()
+	reg.RegisterCommand(HelloInfo, WithContext(CommandFunc(HelloProvider)))
+	reg.RegisterCommand(EchoInfo, WithContext(CommandFunc(EchoProvider)))
+	reg.Fallback = WithContext(CommandFunc(HelloProvider))
+	return reg
+}
@@ -0,0 +1,111 @@
+package lib
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+)
+
+// Code classifies why a command didn't return a result.
+type Code int
+
+const (
+	// CodeInternal means the command failed unexpectedly.
+	CodeInternal Code = iota
+	// CodeInvalidArgument means the command refused the given arguments.
+	CodeInvalidArgument
+	// CodeNotFound means something the arguments refer to doesn't exist.
+	CodeNotFound
+	// CodePermissionDenied means the invoker isn't allowed to use the command.
+	CodePermissionDenied
+	// CodeUnavailable means the command can't be handled right now.
+	CodeUnavailable
+)
+
+var codeNames = map[Code]string{
+	CodeInternal:         "internal",
+	CodeInvalidArgument:  "invalid_argument",
+	CodeNotFound:         "not_found",
+	CodePermissionDenied: "permission_denied",
+	CodeUnavailable:      "unavailable",
+}
+
+var codeStatus = map[Code]int{
+	CodeInternal:         http.StatusInternalServerError,
+	CodeInvalidArgument:  http.StatusBadRequest,
+	CodeNotFound:         http.StatusNotFound,
+	CodePermissionDenied: http.StatusForbidden,
+	CodeUnavailable:      http.StatusServiceUnavailable,
+}
+
+func (code Code) String() string {
+	if name, ok := codeNames[code]; ok {
+		return name
+	}
+	return fmt.Sprintf("code(%d)", int(code))
+}
+
+// MarshalText encodes the code by its name.
+func (code Code) MarshalText() ([]byte, error) {
+	return []byte(code.String()), nil
+}
+
+// UnmarshalText decodes a code by its name. Unknown names are CodeInternal.
+func (code *Code) UnmarshalText(text []byte) error {
+	*code = CodeInternal
+	for c, name := range codeNames {
+		if name == string(text) {
+			*code = c
+		}
+	}
+	return nil
+}
+
+// HTTPStatus returns the http status code used to report the code.
+func (code Code) HTTPStatus() int {
+	if status, ok := codeStatus[code]; ok {
+		return status
+	}
+	return http.StatusInternalServerError
+}
+
+// CodeFromHTTPStatus returns the Code reported with the http status code.
+func CodeFromHTTPStatus(status int) Code {
+	for code, s := range codeStatus {
+		if s == status {
+			return code
+		}
+	}
+	return CodeInternal
+}
+
+// Error is returned by commands which can't handle an invocation.
+type Error struct {
+	Code    Code   `json:"code"`
+	Message string `json:"message"`
+}
+
+// Errorf creates an Error with a formatted message.
+func Errorf(code Code, format string, a ...interface{}) *Error {
+	return &Error{
+		Code:    code,
+		Message: fmt.Sprintf(format, a...),
+	}
+}
+
+func (err *Error) Error() string {
+	return fmt.Sprintf("%s: %s", err.Code, err.Message)
+}
+
+// AsError returns err as *Error. Errors not created by a command
+// are treated as internal errors.
+func AsError(err error) *Error {
+	var cmdErr *Error
+	if errors.As(err, &cmdErr) {
+		return cmdErr
+	}
+	return &Error{
+		Code:    CodeInternal,
+		Message: err.Error(),
+	}
+}
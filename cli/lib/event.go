@@ -0,0 +1,74 @@
+package lib
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"io/ioutil"
+	"net/http"
+	"time"
+)
+
+// Event is a message pushed by a provider without being invoked, e.g. by a
+// timer or a feed watcher. The hub sends the response to the channel on the
+// platform. Without channel the hub decides where to send it.
+type Event struct {
+	Channel  string    `json:"channel,omitempty"`
+	Platform string    `json:"platform,omitempty"`
+	Response *Response `json:"response"`
+}
+
+// EventWebhook pushes events to the webhook of the hub. Web providers use
+// it as they can't send anything on their own otherwise. The token
+// authenticates the provider and is sent as bearer token.
+type EventWebhook struct {
+	URL    string
+	Token  string
+	Client *http.Client
+}
+
+// Push posts the event as JSON to the webhook. Events refused by the hub
+// are returned as *Error.
+func (hook *EventWebhook) Push(ctx context.Context, event *Event) error {
+	body, err := json.Marshal(event)
+	if err != nil {
+		return err
+	}
+	req, err := http.NewRequest(http.MethodPost, hook.URL, bytes.NewReader(body))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	if hook.Token != "" {
+		req.Header.Set("Authorization", "Bearer "+hook.Token)
+	}
+	client := hook.Client
+	if client == nil {
+		client = http.DefaultClient
+	}
+	resp, err := client.Do(req.WithContext(ctx))
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	message, _ := ioutil.ReadAll(resp.Body)
+	if resp.StatusCode < 200 || resp.StatusCode >= 300 {
+		return Errorf(CodeFromHTTPStatus(resp.StatusCode), "event refused: %s", bytes.TrimSpace(message))
+	}
+	return nil
+}
+
+// Announce pushes an event with the text every interval until ctx is done.
+// Failed pushes aren't repeated, the next announcement follows as usual.
+func Announce(ctx context.Context, interval time.Duration, text string, push func(context.Context, *Event) error) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			push(ctx, &Event{Response: Text(text)})
+		}
+	}
+}
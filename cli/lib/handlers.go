@@ -0,0 +1,103 @@
+package lib
+
+import "sync"
+
+// Handlers are the handlers a provider may handle its invocations with.
+// The richest one set handles all of them: RichHandler takes precedence
+// over ContextHandler, which takes precedence over FallibleHandler, which
+// takes precedence over Handler and HandlerFunc.
+type Handlers struct {
+	Handler         Command
+	HandlerFunc     CommandFunc
+	FallibleHandler FallibleCommand
+	ContextHandler  ContextCommand
+	RichHandler     RichCommand
+}
+
+// Command returns the handler taking precedence as RichCommand, or
+// fallback if no handler is set.
+func (handlers Handlers) Command(fallback RichCommand) RichCommand {
+	switch {
+	case handlers.RichHandler != nil:
+		return handlers.RichHandler
+	case handlers.ContextHandler != nil:
+		return Rich(handlers.ContextHandler)
+	case handlers.FallibleHandler != nil:
+		return Rich(FallibleWithContext(handlers.FallibleHandler))
+	case handlers.Handler != nil:
+		return Rich(WithContext(handlers.Handler))
+	case handlers.HandlerFunc != nil:
+		return Rich(WithContext(handlers.HandlerFunc))
+	default:
+		return fallback
+	}
+}
+
+// Drainer counts the invocations in flight of a provider, so they can
+// finish before it shuts down. After Drain further invocations are
+// refused. The zero Drainer is ready to use.
+type Drainer struct {
+	mu       sync.Mutex
+	inFlight int
+	draining bool
+	// drained is closed by Drain
+	drained chan struct{}
+}
+
+// Begin counts an invocation, which has to be ended with End. Invocations
+// begun after Drain fail with CodeUnavailable.
+func (drainer *Drainer) Begin() error {
+	drainer.mu.Lock()
+	defer drainer.mu.Unlock()
+	if drainer.draining {
+		return errShuttingDown
+	}
+	drainer.inFlight++
+	return nil
+}
+
+// End ends an invocation counted by Begin.
+func (drainer *Drainer) End() {
+	drainer.mu.Lock()
+	defer drainer.mu.Unlock()
+	drainer.inFlight--
+}
+
+// Drain refuses all following invocations and closes Drained.
+func (drainer *Drainer) Drain() {
+	drainer.mu.Lock()
+	defer drainer.mu.Unlock()
+	if !drainer.draining {
+		drainer.draining = true
+		close(drainer.drainedChan())
+	}
+}
+
+// Drained returns a channel which is closed by Drain.
+func (drainer *Drainer) Drained() <-chan struct{} {
+	drainer.mu.Lock()
+	defer drainer.mu.Unlock()
+	return drainer.drainedChan()
+}
+
+// drainedChan returns the channel closed by Drain. Must be called with mu held.
+func (drainer *Drainer) drainedChan() chan struct{} {
+	if drainer.drained == nil {
+		drainer.drained = make(chan struct{})
+	}
+	return drainer.drained
+}
+
+// Draining reports whether Drain was called.
+func (drainer *Drainer) Draining() bool {
+	drainer.mu.Lock()
+	defer drainer.mu.Unlock()
+	return drainer.draining
+}
+
+// InFlight returns the number of invocations begun and not ended yet.
+func (drainer *Drainer) InFlight() int {
+	drainer.mu.Lock()
+	defer drainer.mu.Unlock()
+	return drainer.inFlight
+}
@@ -0,0 +1,56 @@
+package lib
+
+import (
+	"context"
+	"testing"
+)
+
+func TestHandlers(t *testing.T) {
+	named := func(name string) CommandFunc {
+		return func(args []string) string { return name }
+	}
+	fallback := Rich(WithContext(named("fallback")))
+	tests := []struct {
+		handlers Handlers
+		expected string
+	}{
+		{handlers: Handlers{}, expected: "fallback"},
+		{handlers: Handlers{HandlerFunc: named("func")}, expected: "func"},
+		{handlers: Handlers{HandlerFunc: named("func"), Handler: named("handler")}, expected: "handler"},
+		{handlers: Handlers{Handler: named("handler"), FallibleHandler: FallibleCommandFunc(func(args []string) (string, error) {
+			return "fallible", nil
+		})}, expected: "fallible"},
+		{handlers: Handlers{HandlerFunc: named("func"), ContextHandler: WithContext(named("context"))}, expected: "context"},
+		{handlers: Handlers{ContextHandler: WithContext(named("context")), RichHandler: Rich(WithContext(named("rich")))}, expected: "rich"},
+	}
+	for _, test := range tests {
+		resp, err := test.handlers.Command(fallback).HandleRich(context.Background(), nil)
+		if err != nil || resp.String() != test.expected {
+			t.Errorf("expected %s, got '%s' (%v)", test.expected, resp.String(), err)
+		}
+	}
+}
+
+func TestDrainer(t *testing.T) {
+	var drainer Drainer
+	if err := drainer.Begin(); err != nil {
+		t.Fatal(err)
+	}
+	drainer.Drain()
+	drainer.Drain()
+	select {
+	case <-drainer.Drained():
+	default:
+		t.Error("drained channel wasn't closed")
+	}
+	if err := drainer.Begin(); AsError(err).Code != CodeUnavailable {
+		t.Errorf("expected CodeUnavailable after drain, got %v", err)
+	}
+	if !drainer.Draining() || drainer.InFlight() != 1 {
+		t.Errorf("invalid state after drain, %d in flight", drainer.InFlight())
+	}
+	drainer.End()
+	if drainer.InFlight() != 0 {
+		t.Errorf("invocation wasn't ended, %d in flight", drainer.InFlight())
+	}
+}
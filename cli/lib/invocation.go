@@ -0,0 +1,103 @@
+package lib
+
+import (
+	"context"
+	"net/http"
+	"strings"
+	"time"
+)
+
+// Invocation describes who invoked a command where. The hub passes it along
+// with the arguments, so commands are able to e.g. check the roles of the
+// sender or count per user.
+type Invocation struct {
+	SenderID   string `json:"sender_id,omitempty"`
+	SenderName string `json:"sender_name,omitempty"`
+	Channel    string `json:"channel,omitempty"`
+	// Platform is the chat platform the invocation was received from, e.g. 'twitch'.
+	Platform string `json:"platform,omitempty"`
+	// Roles of the sender in the channel, e.g. 'moderator' or badges like 'subscriber'.
+	Roles     []string  `json:"roles,omitempty"`
+	MessageID string    `json:"message_id,omitempty"`
+	Timestamp time.Time `json:"timestamp"`
+}
+
+// HasRole reports if the sender has the role. Roles are compared case-insensitive.
+func (inv *Invocation) HasRole(role string) bool {
+	for _, r := range inv.Roles {
+		if strings.EqualFold(r, role) {
+			return true
+		}
+	}
+	return false
+}
+
+type invocationKey struct{}
+
+// WithInvocation returns a copy of ctx carrying the invocation. The hub
+// transmits it to the provider and the provider passes it to the command
+// the same way.
+func WithInvocation(ctx context.Context, inv *Invocation) context.Context {
+	return context.WithValue(ctx, invocationKey{}, inv)
+}
+
+// InvocationFromContext returns the invocation carried by ctx. It's missing
+// if the hub didn't send one or the transport can't carry it, like the
+// plain line protocol.
+func InvocationFromContext(ctx context.Context) (*Invocation, bool) {
+	inv, ok := ctx.Value(invocationKey{}).(*Invocation)
+	return inv, ok && inv != nil
+}
+
+// Headers used to transmit an Invocation over http.
+const (
+	HeaderSenderID   = "X-Sender-Id"
+	HeaderSenderName = "X-Sender-Name"
+	HeaderChannel    = "X-Channel"
+	HeaderPlatform   = "X-Platform"
+	HeaderRoles      = "X-Roles"
+	HeaderMessageID  = "X-Message-Id"
+	HeaderTimestamp  = "X-Timestamp"
+)
+
+// WriteHeader sets the headers describing the invocation. Roles are joined
+// by commas and the timestamp is formatted as RFC 3339.
+func (inv *Invocation) WriteHeader(header http.Header) {
+	set := func(key, value string) {
+		if value != "" {
+			header.Set(key, value)
+		}
+	}
+	set(HeaderSenderID, inv.SenderID)
+	set(HeaderSenderName, inv.SenderName)
+	set(HeaderChannel, inv.Channel)
+	set(HeaderPlatform, inv.Platform)
+	set(HeaderRoles, strings.Join(inv.Roles, ","))
+	set(HeaderMessageID, inv.MessageID)
+	if !inv.Timestamp.IsZero() {
+		header.Set(HeaderTimestamp, inv.Timestamp.Format(time.RFC3339Nano))
+	}
+}
+
+// InvocationFromHeader reads the invocation written by WriteHeader. It
+// returns false if none of the headers is set.
+func InvocationFromHeader(header http.Header) (*Invocation, bool) {
+	inv := &Invocation{
+		SenderID:   header.Get(HeaderSenderID),
+		SenderName: header.Get(HeaderSenderName),
+		Channel:    header.Get(HeaderChannel),
+		Platform:   header.Get(HeaderPlatform),
+		MessageID:  header.Get(HeaderMessageID),
+	}
+	found := inv.SenderID != "" || inv.SenderName != "" || inv.Channel != "" || inv.Platform != "" || inv.MessageID != ""
+	if roles := header.Get(HeaderRoles); roles != "" {
+		inv.Roles = strings.Split(roles, ",")
+		found = true
+	}
+	if timestamp := header.Get(HeaderTimestamp); timestamp != "" {
+		// Invalid timestamps are ignored like missing ones
+		inv.Timestamp, _ = time.Parse(time.RFC3339Nano, timestamp)
+		found = true
+	}
+	return inv, found
+}
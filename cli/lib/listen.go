@@ -0,0 +1,148 @@
+package lib
+
+import (
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"net"
+	"os"
+	"os/user"
+	"path/filepath"
+	"strconv"
+	"sync"
+	"syscall"
+	"time"
+)
+
+// ErrAddressInUse is returned by Listen if a running provider already
+// listens on the unix socket.
+var ErrAddressInUse = errors.New("address in use")
+
+// Listen listens on the network address like net.Listen. A unix socket
+// left behind by a crashed provider is removed first, sockets some
+// provider still answers on are refused with ErrAddressInUse. The socket
+// is removed again when the listener is closed.
+func Listen(network, address string) (net.Listener, error) {
+	if network == "unix" {
+		if err := removeStaleSocket(address); err != nil {
+			return nil, err
+		}
+	}
+	return net.Listen(network, address)
+}
+
+// removeStaleSocket removes the socket file at path if connecting to it is
+// refused. Missing files are ignored and other files refused.
+func removeStaleSocket(path string) error {
+	info, err := os.Lstat(path)
+	if os.IsNotExist(err) {
+		return nil
+	} else if err != nil {
+		return err
+	}
+	if info.Mode()&os.ModeSocket == 0 {
+		return fmt.Errorf("%s exists and isn't a socket", path)
+	}
+	conn, err := net.DialTimeout("unix", path, time.Second)
+	if err == nil {
+		conn.Close()
+		return fmt.Errorf("%w: %s", ErrAddressInUse, path)
+	}
+	// Only a refused connection proves nobody listens, a provider may still
+	// run behind a socket we aren't allowed to connect to or which is busy
+	if !errors.Is(err, syscall.ECONNREFUSED) {
+		return fmt.Errorf("can't tell whether %s is stale: %w", path, err)
+	}
+	return os.Remove(path)
+}
+
+// ListenUnix listens on the unix socket at path like Listen, but with the
+// permissions applied by SetSocketPermissions before anybody is able to
+// connect: the socket is created in a directory only accessible by us and
+// moved to path afterwards. It's removed again when the listener is closed.
+func ListenUnix(path string, mode os.FileMode, owner, group string) (net.Listener, error) {
+	if err := removeStaleSocket(path); err != nil {
+		return nil, err
+	}
+	dir, err := ioutil.TempDir(filepath.Dir(path), ".socket")
+	if err != nil {
+		return nil, err
+	}
+	defer os.RemoveAll(dir)
+	tmp := filepath.Join(dir, "s")
+	lis, err := net.Listen("unix", tmp)
+	if err != nil {
+		return nil, err
+	}
+	// The socket is moved, so it's removed by unixListener instead
+	lis.(*net.UnixListener).SetUnlinkOnClose(false)
+	if err := SetSocketPermissions(tmp, mode, owner, group); err != nil {
+		lis.Close()
+		return nil, err
+	}
+	if err := os.Rename(tmp, path); err != nil {
+		lis.Close()
+		return nil, err
+	}
+	return &unixListener{Listener: lis, path: path}, nil
+}
+
+// unixListener removes its socket when it's closed.
+type unixListener struct {
+	net.Listener
+	path string
+	once sync.Once
+}
+
+func (lis *unixListener) Close() error {
+	err := lis.Listener.Close()
+	lis.once.Do(func() {
+		os.Remove(lis.path)
+	})
+	return err
+}
+
+// SocketPath returns the default path of the unix socket of the provider
+// with the name, e.g. '/tmp/grpcprov.sock', so providers don't share one.
+func SocketPath(name string) string {
+	return filepath.Join(os.TempDir(), name+".sock")
+}
+
+// SetSocketPermissions changes the mode and owner of the unix socket at
+// path, e.g. to 0600 and the user of the hub so nobody else can connect.
+// Owner and group are names or numeric ids. A zero mode and empty owner
+// or group are left unchanged.
+func SetSocketPermissions(path string, mode os.FileMode, owner, group string) error {
+	if mode != 0 {
+		if err := os.Chmod(path, mode); err != nil {
+			return err
+		}
+	}
+	if owner == "" && group == "" {
+		return nil
+	}
+	uid, gid := -1, -1
+	if owner != "" {
+		u, err := user.Lookup(owner)
+		if err != nil {
+			if u, err = user.LookupId(owner); err != nil {
+				return err
+			}
+		}
+		if uid, err = strconv.Atoi(u.Uid); err != nil {
+			return err
+		}
+	}
+	if group != "" {
+		g, err := user.LookupGroup(group)
+		if err != nil {
+			if g, err = user.LookupGroupId(group); err != nil {
+				return err
+			}
+		}
+		if gid, err = strconv.Atoi(g.Gid); err != nil {
+			return err
+		}
+	}
+	return os.Lchown(path, uid, gid)
+}
@@ -0,0 +1,110 @@
+package lib
+
+import (
+	"errors"
+	"io/ioutil"
+	"net"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestListen_StaleSocket(t *testing.T) {
+	dir, err := ioutil.TempDir("", "listen")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	path := filepath.Join(dir, "prov.sock")
+
+	// A crashed provider leaves its socket behind
+	stale, err := net.Listen("unix", path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	stale.(*net.UnixListener).SetUnlinkOnClose(false)
+	stale.Close()
+
+	lis, err := Listen("unix", path)
+	if err != nil {
+		t.Fatalf("stale socket wasn't removed: %v", err)
+	}
+	if _, err := Listen("unix", path); !errors.Is(err, ErrAddressInUse) {
+		t.Errorf("expected ErrAddressInUse for running listener, got %v", err)
+	}
+	lis.Close()
+	if _, err := os.Stat(path); !os.IsNotExist(err) {
+		t.Errorf("socket wasn't removed on close: %v", err)
+	}
+
+	file := filepath.Join(dir, "file")
+	if err := ioutil.WriteFile(file, nil, 0600); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := Listen("unix", file); err == nil {
+		t.Error("expected regular file not to be removed")
+	}
+}
+
+func TestListen_Restricted(t *testing.T) {
+	if os.Geteuid() == 0 {
+		t.Skip("root may connect to any socket")
+	}
+	dir, err := ioutil.TempDir("", "listen")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	path := filepath.Join(dir, "prov.sock")
+
+	// A running provider restricted its socket, so we can't connect
+	lis, err := Listen("unix", path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer lis.Close()
+	if err := os.Chmod(path, 0); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := Listen("unix", path); err == nil {
+		t.Fatal("expected socket which can't be checked to be kept")
+	}
+	if _, err := os.Stat(path); err != nil {
+		t.Errorf("socket of running provider was removed: %v", err)
+	}
+}
+
+func TestListenUnix(t *testing.T) {
+	dir, err := ioutil.TempDir("", "listen")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	path := filepath.Join(dir, "prov.sock")
+
+	lis, err := ListenUnix(path, 0600, "", "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if info, err := os.Stat(path); err != nil || info.Mode().Perm() != 0600 {
+		t.Errorf("invalid socket permissions %v %v", info.Mode(), err)
+	}
+	go func() {
+		if conn, err := lis.Accept(); err == nil {
+			conn.Close()
+		}
+	}()
+	conn, err := net.Dial("unix", path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	conn.Close()
+	if files, _ := ioutil.ReadDir(dir); len(files) != 1 {
+		t.Errorf("expected the socket only, got %d files", len(files))
+	}
+
+	lis.Close()
+	if _, err := os.Stat(path); !os.IsNotExist(err) {
+		t.Errorf("socket wasn't removed on close: %v", err)
+	}
+}
@@ -0,0 +1,34 @@
+package lib
+
+// ProtocolVersion is the version of the protocol spoken between hub and
+// providers. It changes whenever a provider of an older version can't be
+// used by the hub anymore.
+const ProtocolVersion = 1
+
+// Version is the version of the providers in this repository.
+const Version = "0.1.0"
+
+// Manifest describes a provider and the commands it offers.
+type Manifest struct {
+	Name            string        `json:"name"`
+	Version         string        `json:"version"`
+	ProtocolVersion int           `json:"protocol_version"`
+	Commands        []CommandInfo `json:"commands"`
+}
+
+// CommandInfo describes a single command.
+type CommandInfo struct {
+	Name        string `json:"name"`
+	Usage       string `json:"usage,omitempty"`
+	Description string `json:"description,omitempty"`
+}
+
+// NewManifest creates a Manifest for the current protocol version.
+func NewManifest(name, version string, commands ...CommandInfo) *Manifest {
+	return &Manifest{
+		Name:            name,
+		Version:         version,
+		ProtocolVersion: ProtocolVersion,
+		Commands:        commands,
+	}
+}
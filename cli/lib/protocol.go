@@ -0,0 +1,73 @@
+package lib
+
+import (
+	"encoding/json"
+	"io"
+	"time"
+)
+
+const (
+	// FrameCancel is the type of frames canceling the invocation with the same ID.
+	FrameCancel = "cancel"
+	// FrameHello is the type of the first frame written by a provider. It carries the Manifest.
+	FrameHello = "hello"
+	// FrameChunk is the type of frames carrying a part of the response of
+	// the invocation with the same ID. The response frame without type
+	// follows as end marker.
+	FrameChunk = "chunk"
+	// FrameEvent is the type of frames carrying an Event pushed by the
+	// provider. Its ID is always zero.
+	FrameEvent = "event"
+)
+
+// Frame is a single line of the framed protocol. Requests carry the arguments,
+// an optional deadline and the invocation, and responses either the result, the structured
+// response or the error.
+// A response carries the ID of its request so responses can be matched even
+// if they're written out of order.
+//
+// Frames without type are requests or responses. Other frames are marked
+// by Type, e.g. FrameCancel.
+type Frame struct {
+	ID       uint64     `json:"id"`
+	Type     string     `json:"type,omitempty"`
+	Args     []string   `json:"args,omitempty"`
+	Deadline *time.Time `json:"deadline,omitempty"`
+	// Invocation describes who invoked the command. Only set by requests.
+	Invocation *Invocation `json:"invocation,omitempty"`
+	Result     string      `json:"result,omitempty"`
+	Response   *Response   `json:"response,omitempty"`
+	Error      *Error      `json:"error,omitempty"`
+	Manifest   *Manifest   `json:"manifest,omitempty"`
+	Event      *Event      `json:"event,omitempty"`
+}
+
+// NewResponseFrame creates the frame answering the request with the ID.
+// Single text replies are set as Result and all other responses as Response.
+func NewResponseFrame(id uint64, resp *Response, err error) *Frame {
+	frame := &Frame{ID: id}
+	switch {
+	case err != nil:
+		frame.Error = AsError(err)
+	case resp.IsText():
+		frame.Result = resp.String()
+	default:
+		frame.Response = resp
+	}
+	return frame
+}
+
+// ParseFrame decodes a single line of the framed protocol.
+func ParseFrame(line []byte) (*Frame, error) {
+	var frame Frame
+	err := json.Unmarshal(line, &frame)
+	if err != nil {
+		return nil, err
+	}
+	return &frame, nil
+}
+
+// WriteFrame encodes the frame as a single line to the writer.
+func WriteFrame(w io.Writer, frame *Frame) error {
+	return json.NewEncoder(w).Encode(frame)
+}
@@ -2,78 +2,383 @@ package lib
 
 import (
 	"bufio"
+	"context"
+	"errors"
 	"fmt"
 	"io"
 	"strings"
+	"sync"
 )
 
 // ReaderWriterProvider implements the ReaderWriterProvider interface
 // by using io.Stdin and io.Stdout for communication.
+//
+// By default every line read is an invocation, split into arguments by
+// SplitArgs, and every line written is the result of the invocation with
+// the same position. If Framed is
+// set both are exchanged as Frames, which allows the hub to correlate
+// results with invocations by their ID and to cancel them.
+//
+// The handler is chosen like Handlers does, EchoProvider is used without
+// any. Errors are written as error frames in framed mode. Structured responses
+// are written as response of the frame unless they're a single text
+// message, which is written as result. Plain mode only writes the text of
+// all messages on a single line.
+//
+// Chunks sent with SendChunk are written as chunk frames right away in
+// framed mode, followed by the response frame. Plain mode collects them.
+//
+// Push writes events in framed mode, so the provider can send messages
+// without being invoked.
+//
+// In framed mode the provider first writes a hello frame with the Manifest,
+// so the hub knows which commands and protocol version are provided.
+//
+// In framed mode up to MaxInFlight handlers run concurrently and results
+// are written as soon as they're done. Ordered runs the handlers one after
+// another instead, which is always the case in plain mode.
+//
+// Shutdown drains the provider: invocations read afterwards are refused
+// and the ones in flight are finished, including writing their results.
 type ReaderWriterProvider struct {
-	Input  io.Reader
-	Output io.Writer
+	Input    io.Reader
+	Output   io.Writer
+	Framed   bool
+	Manifest *Manifest
 
-	Handler     Command
-	HandlerFunc CommandFunc
+	MaxInFlight int
+	Ordered     bool
+
+	Handler         Command
+	HandlerFunc     CommandFunc
+	FallibleHandler FallibleCommand
+	ContextHandler  ContextCommand
+	RichHandler     RichCommand
+
+	mu       sync.Mutex
+	inFlight map[uint64]context.CancelFunc
+	// base is the parent of all request contexts, canceled by abort.
+	base  context.Context
+	abort context.CancelFunc
+	// pending counts the invocations read whose result isn't written yet.
+	pending  int
+	draining bool
+	drained  chan struct{}
+
+	// events passes pushed events to the output, which closes stopped when done
+	events  chan *Event
+	stopped chan struct{}
+}
+
+// errShuttingDown is returned for invocations read while draining.
+var errShuttingDown = Errorf(CodeUnavailable, "provider is shutting down")
+
+type request struct {
+	ctx  context.Context
+	id   uint64
+	args []string
+	// err is set if the invocation couldn't be parsed
+	err error
+}
+
+type response struct {
+	id     uint64
+	result *Response
+	err    *Error
+	// chunk is set if the response is followed by more
+	chunk bool
+}
+
+func (prov *ReaderWriterProvider) command() RichCommand {
+	return Handlers{
+		Handler:         prov.Handler,
+		HandlerFunc:     prov.HandlerFunc,
+		FallibleHandler: prov.FallibleHandler,
+		ContextHandler:  prov.ContextHandler,
+		RichHandler:     prov.RichHandler,
+	}.Command(Rich(WithContext(CommandFunc(EchoProvider))))
+}
+
+func handle(cmd RichCommand, req request) (result *Response, err error) {
+	defer func() {
+		if r := recover(); r != nil {
+			err = fmt.Errorf("handler panicked: %v", r)
+		}
+	}()
+	result, err = cmd.HandleRich(req.ctx, req.args)
+	if err == nil && result == nil {
+		result = NoReply()
+	}
+	return result, err
+}
+
+// workers returns the number of handlers allowed to run at the same time.
+func (prov *ReaderWriterProvider) workers() int {
+	if !prov.Framed || prov.Ordered || prov.MaxInFlight < 1 {
+		return 1
+	}
+	return prov.MaxInFlight
 }
 
-func (prov *ReaderWriterProvider) listen(input <-chan []string) <-chan string {
-	output := make(chan string)
+func (prov *ReaderWriterProvider) listen(input <-chan request) <-chan response {
+	output := make(chan response)
+	cmd := prov.command()
+	if !prov.Framed {
+		cmd = Collect(cmd)
+	}
+	workers := prov.workers()
 	go func() {
 		defer close(output)
-	loop:
-		for {
-			select {
-			case args, ok := <-input:
-				if !ok {
-					break loop
+		var wg sync.WaitGroup
+		// Requests wait for a free slot in their own goroutine, so cancel
+		// frames are still read while all slots are taken.
+		slots := make(chan struct{}, workers)
+		// With a single slot every request waits for its predecessor
+		// to be passed on, so the responses keep the input order.
+		var previous <-chan struct{}
+		for req := range input {
+			done := make(chan struct{})
+			wg.Add(1)
+			go func(req request, previous <-chan struct{}, done chan<- struct{}) {
+				defer wg.Done()
+				defer close(done)
+				if previous != nil {
+					<-previous
 				}
-				if len(args) == 0 {
-					continue
+				slots <- struct{}{}
+				var result *Response
+				err := req.err
+				if err == nil {
+					req.ctx = prov.chunks(req, output)
+					result, err = handle(cmd, req)
 				}
-				var value string
-				if prov.Handler == nil {
-					if prov.HandlerFunc == nil {
-						value = EchoProvider(args)
-					} else {
-						value = prov.HandlerFunc(args)
-					}
-				} else {
-					value = prov.Handler.Handle(args)
+				prov.finish(req.id)
+				resp := response{id: req.id, result: result}
+				if err != nil {
+					resp.err = AsError(err)
 				}
-				output <- value
+				output <- resp
+				<-slots
+			}(req, previous, done)
+			if workers == 1 {
+				previous = done
 			}
 		}
+		wg.Wait()
 	}()
 	return output
 }
 
+// eventOutput returns the channels passing events to the output.
+func (prov *ReaderWriterProvider) eventOutput() (chan *Event, chan struct{}) {
+	prov.mu.Lock()
+	defer prov.mu.Unlock()
+	if prov.events == nil {
+		prov.events = make(chan *Event)
+		prov.stopped = make(chan struct{})
+	}
+	return prov.events, prov.stopped
+}
+
+// ErrNotFramed is returned by Push of a provider in plain mode.
+var ErrNotFramed = errors.New("events can only be pushed in framed mode")
+
+// ErrProviderStopped is returned by Push after the output of the provider was closed.
+var ErrProviderStopped = errors.New("provider stopped")
+
+// Push writes the event to the output. It blocks until the event is
+// written, ctx is done or the provider stopped.
+func (prov *ReaderWriterProvider) Push(ctx context.Context, event *Event) error {
+	if !prov.Framed {
+		return ErrNotFramed
+	}
+	events, stopped := prov.eventOutput()
+	select {
+	case events <- event:
+		return nil
+	case <-stopped:
+		return ErrProviderStopped
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
+
+// chunks returns the context of the request passing chunks to the output.
+func (prov *ReaderWriterProvider) chunks(req request, output chan<- response) context.Context {
+	return WithChunks(req.ctx, func(chunk *Response) error {
+		select {
+		case output <- response{id: req.id, result: chunk, chunk: true}:
+			return nil
+		case <-req.ctx.Done():
+			return req.ctx.Err()
+		}
+	})
+}
+
 // proxy between the raw output writer and output channel
-func (prov *ReaderWriterProvider) outputProxy(output <-chan string) <-chan struct{} {
+func (prov *ReaderWriterProvider) outputProxy(output <-chan response) <-chan struct{} {
 	out := make(chan struct{})
+	events, stopped := prov.eventOutput()
 	go func() {
 		defer close(out)
-		for out := range output {
-			_, err := fmt.Fprintln(prov.Output, out)
+		defer close(stopped)
+		if prov.Framed {
+			manifest := prov.Manifest
+			if manifest == nil {
+				manifest = NewManifest("", "")
+			}
+			err := WriteFrame(prov.Output, &Frame{
+				Type:     FrameHello,
+				Manifest: manifest,
+			})
+			if err != nil {
+				return
+			}
+		}
+		for {
+			var out response
+			var ok bool
+			select {
+			case out, ok = <-output:
+			case event := <-events:
+				if err := WriteFrame(prov.Output, &Frame{Type: FrameEvent, Event: event}); err != nil {
+					return
+				}
+				continue
+			}
+			if !ok {
+				return
+			}
+			var err error
+			if prov.Framed {
+				var frame *Frame
+				if out.err != nil {
+					frame = NewResponseFrame(out.id, nil, out.err)
+				} else {
+					frame = NewResponseFrame(out.id, out.result, nil)
+				}
+				if out.chunk {
+					frame.Type = FrameChunk
+				}
+				err = WriteFrame(prov.Output, frame)
+			} else if out.err != nil {
+				// Plain lines can't carry the error, so only its message is written
+				_, err = fmt.Fprintln(prov.Output, out.err.Message)
+			} else {
+				// Messages are joined to a single line to keep the positions
+				_, err = fmt.Fprintln(prov.Output, strings.ReplaceAll(out.result.String(), "\n", " "))
+			}
 			if err != nil {
 				break
 			}
+			if !out.chunk {
+				prov.written()
+			}
 		}
 	}()
 	return out
 }
 
-func (prov *ReaderWriterProvider) inputProxy() <-chan []string {
-	out := make(chan []string)
+// baseContext returns the parent of all request contexts. Must be called
+// with mu held.
+func (prov *ReaderWriterProvider) baseContext() context.Context {
+	if prov.base == nil {
+		prov.base, prov.abort = context.WithCancel(context.Background())
+	}
+	return prov.base
+}
+
+// accept counts a request as pending and returns the base context for it.
+// The error is set if the provider is draining and the request has to be
+// refused.
+func (prov *ReaderWriterProvider) accept() (context.Context, error) {
+	prov.mu.Lock()
+	defer prov.mu.Unlock()
+	prov.pending++
+	if prov.draining {
+		return prov.baseContext(), errShuttingDown
+	}
+	return prov.baseContext(), nil
+}
+
+// written marks the result of a pending request as written.
+func (prov *ReaderWriterProvider) written() {
+	prov.mu.Lock()
+	defer prov.mu.Unlock()
+	prov.pending--
+	if prov.drained != nil && prov.pending == 0 {
+		close(prov.drained)
+		prov.drained = nil
+	}
+}
+
+// begin creates the context of a framed request, which is canceled by a
+// cancel frame with the same ID or when its deadline passes. It carries the
+// invocation of the frame if set.
+func (prov *ReaderWriterProvider) begin(frame *Frame) context.Context {
+	prov.mu.Lock()
+	defer prov.mu.Unlock()
+	var ctx context.Context
+	var cancel context.CancelFunc
+	if frame.Deadline != nil {
+		ctx, cancel = context.WithDeadline(prov.baseContext(), *frame.Deadline)
+	} else {
+		ctx, cancel = context.WithCancel(prov.baseContext())
+	}
+	if prov.inFlight == nil {
+		prov.inFlight = make(map[uint64]context.CancelFunc)
+	}
+	prov.inFlight[frame.ID] = cancel
+	if frame.Invocation != nil {
+		return WithInvocation(ctx, frame.Invocation)
+	}
+	return ctx
+}
+
+// finish releases the context of a request.
+func (prov *ReaderWriterProvider) finish(id uint64) {
+	prov.mu.Lock()
+	defer prov.mu.Unlock()
+	if cancel, ok := prov.inFlight[id]; ok {
+		cancel()
+		delete(prov.inFlight, id)
+	}
+}
+
+func (prov *ReaderWriterProvider) inputProxy() <-chan request {
+	out := make(chan request)
 	go func() {
 		defer close(out)
 		reader := bufio.NewReader(prov.Input)
+		var id uint64
 		for {
 			line, err := reader.ReadString('\n')
 			if err != nil {
 				break
 			}
-			out <- strings.Split(strings.TrimSpace(line), " ")
+			if !prov.Framed {
+				// Every line is answered, even if it can't be parsed
+				id++
+				args, err := SplitArgs(line)
+				ctx, acceptErr := prov.accept()
+				if acceptErr != nil {
+					err = acceptErr
+				}
+				out <- request{ctx: ctx, id: id, args: args, err: err}
+				continue
+			}
+			frame, err := ParseFrame([]byte(line))
+			if err != nil {
+				// Without a valid frame there is no ID to answer to
+				continue
+			}
+			switch frame.Type {
+			case "":
+				_, err := prov.accept()
+				out <- request{ctx: prov.begin(frame), id: frame.ID, args: frame.Args, err: err}
+			case FrameCancel:
+				prov.finish(frame.ID)
+			}
 		}
 	}()
 	return out
@@ -85,3 +390,34 @@ func (prov *ReaderWriterProvider) Start() <-chan struct{} {
 	output := prov.listen(input)
 	return prov.outputProxy(output)
 }
+
+// Shutdown stops accepting invocations and waits until the ones in flight
+// are done and their results are written. If ctx is done before, the
+// remaining invocations are canceled and their number is returned together
+// with the error of ctx.
+func (prov *ReaderWriterProvider) Shutdown(ctx context.Context) (abandoned int, err error) {
+	prov.mu.Lock()
+	if !prov.draining {
+		prov.draining = true
+		if prov.pending > 0 {
+			prov.drained = make(chan struct{})
+		}
+	}
+	drained := prov.drained
+	prov.mu.Unlock()
+	if drained == nil {
+		return 0, nil
+	}
+
+	select {
+	case <-drained:
+		return 0, nil
+	case <-ctx.Done():
+	}
+	prov.mu.Lock()
+	defer prov.mu.Unlock()
+	abandoned = prov.pending
+	prov.baseContext()
+	prov.abort()
+	return abandoned, ctx.Err()
+}
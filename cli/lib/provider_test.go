@@ -0,0 +1,303 @@
+package lib
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"io"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+)
+
+func TestReaderWriterProvider(t *testing.T) {
+	var out bytes.Buffer
+	provider := &ReaderWriterProvider{
+		Input:       strings.NewReader("Kevin\nMary Ann\n\"Mary Ann\"\n\n\"Kevin\n"),
+		Output:      &out,
+		HandlerFunc: HelloProvider,
+	}
+	<-provider.Start()
+
+	expected := "Hello, Kevin!\nHello, Mary!\nHello, Mary Ann!\nHello!\nunterminated quote\n"
+	if out.String() != expected {
+		t.Errorf("invalid output '%s', expected '%s'", out.String(), expected)
+	}
+}
+
+func TestReaderWriterProvider_Framed(t *testing.T) {
+	var out bytes.Buffer
+	provider := &ReaderWriterProvider{
+		Input:       strings.NewReader("{\"id\":7,\"args\":[\"Kevin\"]}\nnot a frame\n{\"id\":8}\n"),
+		Output:      &out,
+		Framed:      true,
+		HandlerFunc: HelloProvider,
+	}
+	<-provider.Start()
+
+	expected := []Frame{
+		{ID: 0, Type: FrameHello},
+		{ID: 7, Result: "Hello, Kevin!"},
+		{ID: 8, Result: "Hello!"},
+	}
+	scanner := bufio.NewScanner(&out)
+	for _, exp := range expected {
+		if !scanner.Scan() {
+			t.Fatalf("missing frame %d", exp.ID)
+		}
+		frame, err := ParseFrame(scanner.Bytes())
+		if err != nil {
+			t.Fatal(err)
+		}
+		if frame.ID != exp.ID || frame.Type != exp.Type || frame.Result != exp.Result {
+			t.Errorf("invalid frame %+v, expected %+v", *frame, exp)
+		}
+	}
+	if scanner.Scan() {
+		t.Errorf("unexpected output '%s'", scanner.Text())
+	}
+}
+
+func TestReaderWriterProvider_MaxInFlight(t *testing.T) {
+	// Every handler waits until both invocations are in flight
+	var wg sync.WaitGroup
+	wg.Add(2)
+	inReader, inWriter := io.Pipe()
+	outReader, outWriter := io.Pipe()
+	provider := &ReaderWriterProvider{
+		Input:       inReader,
+		Output:      outWriter,
+		Framed:      true,
+		MaxInFlight: 2,
+		HandlerFunc: func(args []string) string {
+			wg.Done()
+			wg.Wait()
+			return HelloProvider(args)
+		},
+	}
+	provider.Start()
+	defer inWriter.Close()
+
+	go func() {
+		WriteFrame(inWriter, &Frame{ID: 1, Args: []string{"Kevin"}})
+		WriteFrame(inWriter, &Frame{ID: 2, Args: []string{"Mary"}})
+	}()
+
+	done := make(chan map[uint64]string)
+	go func() {
+		results := make(map[uint64]string)
+		reader := bufio.NewReader(outReader)
+		for len(results) < 2 {
+			line, err := reader.ReadBytes('\n')
+			if err != nil {
+				break
+			}
+			frame, err := ParseFrame(line)
+			if err != nil {
+				break
+			}
+			if frame.Type == "" {
+				results[frame.ID] = frame.Result
+			}
+		}
+		done <- results
+	}()
+
+	select {
+	case results := <-done:
+		if results[1] != "Hello, Kevin!" || results[2] != "Hello, Mary!" {
+			t.Errorf("invalid results %v", results)
+		}
+	case <-time.After(time.Second):
+		t.Fatal("handlers weren't executed concurrently")
+	}
+}
+
+func TestReaderWriterProvider_Cancel(t *testing.T) {
+	inReader, inWriter := io.Pipe()
+	outReader, outWriter := io.Pipe()
+	provider := &ReaderWriterProvider{
+		Input:  inReader,
+		Output: outWriter,
+		Framed: true,
+		ContextHandler: ContextCommandFunc(func(ctx context.Context, args []string) (string, error) {
+			<-ctx.Done()
+			return "", ctx.Err()
+		}),
+	}
+	provider.Start()
+	defer inWriter.Close()
+
+	go func() {
+		past := time.Now().Add(-time.Second)
+		WriteFrame(inWriter, &Frame{ID: 1, Args: []string{"Kevin"}})
+		WriteFrame(inWriter, &Frame{ID: 2, Args: []string{"Mary"}, Deadline: &past})
+		WriteFrame(inWriter, &Frame{ID: 1, Type: FrameCancel})
+	}()
+
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		reader := bufio.NewReader(outReader)
+		// Skip the hello frame
+		if _, err := reader.ReadBytes('\n'); err != nil {
+			t.Error(err)
+			return
+		}
+		for _, expected := range []string{context.Canceled.Error(), context.DeadlineExceeded.Error()} {
+			line, err := reader.ReadBytes('\n')
+			if err != nil {
+				t.Error(err)
+				return
+			}
+			frame, err := ParseFrame(line)
+			if err != nil {
+				t.Error(err)
+				return
+			}
+			if frame.Error == nil || frame.Error.Message != expected {
+				t.Errorf("invalid frame %+v, expected error '%s'", *frame, expected)
+			}
+		}
+	}()
+
+	select {
+	case <-done:
+	case <-time.After(time.Second):
+		t.Fatal("handlers weren't canceled")
+	}
+}
+
+func TestReaderWriterProvider_Shutdown(t *testing.T) {
+	inReader, inWriter := io.Pipe()
+	outReader, outWriter := io.Pipe()
+	release := make(chan struct{})
+	started := make(chan struct{}, 2)
+	provider := &ReaderWriterProvider{
+		Input:       inReader,
+		Output:      outWriter,
+		Framed:      true,
+		MaxInFlight: 2,
+		ContextHandler: ContextCommandFunc(func(ctx context.Context, args []string) (string, error) {
+			started <- struct{}{}
+			if args[0] == "slow" {
+				<-ctx.Done()
+				return "", ctx.Err()
+			}
+			<-release
+			return "done", nil
+		}),
+	}
+	provider.Start()
+	defer inWriter.Close()
+
+	frames := make(chan *Frame)
+	go func() {
+		defer close(frames)
+		reader := bufio.NewReader(outReader)
+		for {
+			line, err := reader.ReadBytes('\n')
+			if err != nil {
+				return
+			}
+			frame, err := ParseFrame(line)
+			if err != nil {
+				t.Error(err)
+				return
+			}
+			if frame.Type == "" {
+				frames <- frame
+			}
+		}
+	}()
+
+	WriteFrame(inWriter, &Frame{ID: 1, Args: []string{"fast"}})
+	WriteFrame(inWriter, &Frame{ID: 2, Args: []string{"slow"}})
+	<-started
+	<-started
+
+	type result struct {
+		abandoned int
+		err       error
+	}
+	shutdown := make(chan result)
+	go func() {
+		ctx, cancel := context.WithTimeout(context.Background(), 100*time.Millisecond)
+		defer cancel()
+		abandoned, err := provider.Shutdown(ctx)
+		shutdown <- result{abandoned, err}
+	}()
+
+	// In-flight invocations are finished
+	close(release)
+	frame := <-frames
+	if frame.ID != 1 || frame.Result != "done" {
+		t.Errorf("invalid frame %+v, expected result of the first invocation", *frame)
+	}
+
+	// The slow one is abandoned after the grace period
+	select {
+	case res := <-shutdown:
+		if res.abandoned != 1 || res.err != context.DeadlineExceeded {
+			t.Errorf("invalid shutdown result %+v", res)
+		}
+	case <-time.After(time.Second):
+		t.Fatal("shutdown didn't return after the grace period")
+	}
+	frame = <-frames
+	if frame.ID != 2 || frame.Error == nil {
+		t.Errorf("invalid frame %+v, expected canceled invocation", *frame)
+	}
+
+	// Invocations are refused after draining
+	WriteFrame(inWriter, &Frame{ID: 3, Args: []string{"fast"}})
+	frame = <-frames
+	if frame.ID != 3 || frame.Error == nil || frame.Error.Code != CodeUnavailable {
+		t.Errorf("invalid frame %+v, expected unavailable error", *frame)
+	}
+}
+
+func TestReaderWriterProvider_Push(t *testing.T) {
+	inReader, inWriter := io.Pipe()
+	outReader, outWriter := io.Pipe()
+	provider := &ReaderWriterProvider{
+		Input:  inReader,
+		Output: outWriter,
+		Framed: true,
+	}
+	done := provider.Start()
+
+	go func() {
+		err := provider.Push(context.Background(), &Event{Channel: "#general", Response: Text("tick")})
+		if err != nil {
+			t.Error(err)
+		}
+	}()
+	reader := bufio.NewReader(outReader)
+	// Skip the hello frame
+	if _, err := reader.ReadBytes('\n'); err != nil {
+		t.Fatal(err)
+	}
+	line, err := reader.ReadBytes('\n')
+	if err != nil {
+		t.Fatal(err)
+	}
+	frame, err := ParseFrame(line)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if frame.Type != FrameEvent || frame.Event == nil || frame.Event.Channel != "#general" || frame.Event.Response.String() != "tick" {
+		t.Errorf("invalid event frame %+v", *frame)
+	}
+
+	inWriter.Close()
+	<-done
+	if err := provider.Push(context.Background(), &Event{}); err != ErrProviderStopped {
+		t.Errorf("expected ErrProviderStopped, got %v", err)
+	}
+	plain := &ReaderWriterProvider{}
+	if err := plain.Push(context.Background(), &Event{}); err != ErrNotFramed {
+		t.Errorf("expected ErrNotFramed, got %v", err)
+	}
+}
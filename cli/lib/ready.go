@@ -0,0 +1,16 @@
+package lib
+
+import (
+	"fmt"
+	"io"
+)
+
+// ReadyLine is written to stderr by providers as soon as they're able to
+// handle invocations. The hub waits for it instead of guessing startup times.
+const ReadyLine = "ready"
+
+// AnnounceReady writes the ReadyLine to w, which is usually os.Stderr.
+func AnnounceReady(w io.Writer) error {
+	_, err := fmt.Fprintln(w, ReadyLine)
+	return err
+}
@@ -0,0 +1,124 @@
+package lib
+
+import (
+	"context"
+	"sort"
+	"strings"
+	"sync"
+)
+
+// Registry is a ContextCommand and RichCommand routing invocations to the command registered
+// with the name given as first argument. The remaining arguments are passed
+// to the command. Names are matched case-insensitive.
+//
+// Invocations of unknown commands are passed to Fallback with all arguments,
+// which allows registering commands next to a single handler. Without
+// Fallback they fail with CodeNotFound.
+type Registry struct {
+	Fallback ContextCommand
+
+	mu       sync.RWMutex
+	commands map[string]registered
+}
+
+type registered struct {
+	info CommandInfo
+	cmd  RichCommand
+}
+
+// NewRegistry creates an empty Registry.
+func NewRegistry() *Registry {
+	return &Registry{
+		commands: make(map[string]registered),
+	}
+}
+
+// Register registers the command with the name, replacing any command
+// registered with the same name before.
+func (reg *Registry) Register(name string, cmd ContextCommand) {
+	reg.RegisterCommand(CommandInfo{Name: name}, cmd)
+}
+
+// RegisterCommand registers the command with the name of info like Register.
+// The info is listed by Describe.
+func (reg *Registry) RegisterCommand(info CommandInfo, cmd ContextCommand) {
+	reg.RegisterRich(info, Rich(cmd))
+}
+
+// RegisterRich registers the command returning structured responses like
+// RegisterCommand.
+func (reg *Registry) RegisterRich(info CommandInfo, cmd RichCommand) {
+	reg.mu.Lock()
+	defer reg.mu.Unlock()
+	if reg.commands == nil {
+		reg.commands = make(map[string]registered)
+	}
+	info.Name = strings.ToLower(info.Name)
+	reg.commands[info.Name] = registered{info: info, cmd: cmd}
+}
+
+// RegisterFunc registers the function with the name like Register.
+func (reg *Registry) RegisterFunc(name string, fn CommandFunc) {
+	reg.Register(name, WithContext(fn))
+}
+
+// Commands returns the sorted names of all registered commands.
+func (reg *Registry) Commands() []string {
+	reg.mu.RLock()
+	defer reg.mu.RUnlock()
+	names := make([]string, 0, len(reg.commands))
+	for name := range reg.commands {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// Lookup returns the command registered with the name.
+func (reg *Registry) Lookup(name string) (RichCommand, bool) {
+	reg.mu.RLock()
+	defer reg.mu.RUnlock()
+	entry, ok := reg.commands[strings.ToLower(name)]
+	return entry.cmd, ok
+}
+
+// Describe returns the infos of all registered commands sorted by name.
+func (reg *Registry) Describe() []CommandInfo {
+	names := reg.Commands()
+	reg.mu.RLock()
+	defer reg.mu.RUnlock()
+	infos := make([]CommandInfo, 0, len(names))
+	for _, name := range names {
+		infos = append(infos, reg.commands[name].info)
+	}
+	return infos
+}
+
+// Handle routes the invocation by its first argument. Structured responses
+// are reduced to their text, a nil response means no reply.
+func (reg *Registry) Handle(ctx context.Context, args []string) (string, error) {
+	resp, err := reg.HandleRich(ctx, args)
+	if err != nil {
+		return "", err
+	}
+	if resp == nil {
+		resp = NoReply()
+	}
+	return resp.String(), nil
+}
+
+// HandleRich routes the invocation by its first argument.
+func (reg *Registry) HandleRich(ctx context.Context, args []string) (*Response, error) {
+	if len(args) > 0 {
+		if cmd, ok := reg.Lookup(args[0]); ok {
+			return cmd.HandleRich(ctx, args[1:])
+		}
+	}
+	if reg.Fallback != nil {
+		return Rich(reg.Fallback).HandleRich(ctx, args)
+	}
+	if len(args) == 0 {
+		return nil, Errorf(CodeInvalidArgument, "no command given")
+	}
+	return nil, Errorf(CodeNotFound, "unknown command '%s'", args[0])
+}
@@ -0,0 +1,55 @@
+package lib
+
+import (
+	"context"
+	"reflect"
+	"testing"
+)
+
+func TestRegistry(t *testing.T) {
+	reg := NewRegistry()
+	reg.RegisterFunc("hello", HelloProvider)
+	reg.RegisterFunc("Echo", EchoProvider)
+
+	if commands := reg.Commands(); !reflect.DeepEqual(commands, []string{"echo", "hello"}) {
+		t.Errorf("invalid commands %v", commands)
+	}
+
+	tests := []struct {
+		args     []string
+		expected string
+		code     Code
+	}{
+		{args: []string{"hello", "Kevin"}, expected: "Hello, Kevin!"},
+		{args: []string{"HELLO"}, expected: "Hello!"},
+		{args: []string{"echo", "a", "b"}, expected: "a b"},
+		{args: []string{"roll"}, code: CodeNotFound},
+		{args: []string{}, code: CodeInvalidArgument},
+	}
+	for _, test := range tests {
+		result, err := reg.Handle(context.Background(), test.args)
+		if test.expected != "" {
+			if err != nil || result != test.expected {
+				t.Errorf("%v: expected '%s', got '%s' (%v)", test.args, test.expected, result, err)
+			}
+			continue
+		}
+		if err == nil || AsError(err).Code != test.code {
+			t.Errorf("%v: expected code %s, got %v", test.args, test.code, err)
+		}
+	}
+
+	// Rich commands may return nil for no reply
+	reg.RegisterRich(CommandInfo{Name: "quiet"}, RichCommandFunc(func(ctx context.Context, args []string) (*Response, error) {
+		return nil, nil
+	}))
+	if result, err := reg.Handle(context.Background(), []string{"quiet"}); err != nil || result != "" {
+		t.Errorf("expected no reply, got '%s' (%v)", result, err)
+	}
+
+	reg.Fallback = WithContext(CommandFunc(HelloProvider))
+	result, err := reg.Handle(context.Background(), []string{"Kevin"})
+	if err != nil || result != "Hello, Kevin!" {
+		t.Errorf("fallback not used, got '%s' (%v)", result, err)
+	}
+}
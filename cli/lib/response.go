@@ -0,0 +1,155 @@
+package lib
+
+import (
+	"context"
+	"strings"
+)
+
+// Target tells the hub where a message has to be sent to.
+type Target string
+
+const (
+	// TargetReply answers the invoker, e.g. as reply to the invoking message.
+	// It's the default if no target is set.
+	TargetReply Target = "reply"
+	// TargetBroadcast sends the message to everyone in the channel of the invocation.
+	TargetBroadcast Target = "broadcast"
+)
+
+// Mention refers to a user, which is notified by the platform.
+type Mention struct {
+	UserID string `json:"user_id"`
+	Name   string `json:"name,omitempty"`
+}
+
+// Embed is a rich preview shown below the text of a message.
+type Embed struct {
+	Title       string `json:"title,omitempty"`
+	Description string `json:"description,omitempty"`
+	URL         string `json:"url,omitempty"`
+	ImageURL    string `json:"image_url,omitempty"`
+}
+
+// Attachment is a file sent with a message. It's either referred to by URL
+// or sent along as Data.
+type Attachment struct {
+	Name        string `json:"name"`
+	ContentType string `json:"content_type,omitempty"`
+	URL         string `json:"url,omitempty"`
+	Data        []byte `json:"data,omitempty"`
+}
+
+// Message is a single message sent by the hub as result of an invocation.
+type Message struct {
+	Text        string       `json:"text,omitempty"`
+	Target      Target       `json:"target,omitempty"`
+	Mentions    []Mention    `json:"mentions,omitempty"`
+	Embeds      []Embed      `json:"embeds,omitempty"`
+	Attachments []Attachment `json:"attachments,omitempty"`
+}
+
+// Mention adds a mention of the user to the message.
+func (msg *Message) Mention(userID, name string) *Message {
+	msg.Mentions = append(msg.Mentions, Mention{UserID: userID, Name: name})
+	return msg
+}
+
+// Embed adds the embed to the message.
+func (msg *Message) Embed(embed Embed) *Message {
+	msg.Embeds = append(msg.Embeds, embed)
+	return msg
+}
+
+// Attach adds the attachment to the message.
+func (msg *Message) Attach(attachment Attachment) *Message {
+	msg.Attachments = append(msg.Attachments, attachment)
+	return msg
+}
+
+// isText reports if the message is a reply consisting of text only.
+func (msg *Message) isText() bool {
+	return (msg.Target == "" || msg.Target == TargetReply) &&
+		len(msg.Mentions) == 0 && len(msg.Embeds) == 0 && len(msg.Attachments) == 0
+}
+
+// Response is the structured result of an invocation. It consists of any
+// number of messages sent in order. A Response without messages tells the
+// hub not to reply at all.
+type Response struct {
+	Messages []*Message `json:"messages"`
+}
+
+// Text creates a Response with a single reply containing the text.
+// That's what plain string results are mapped to.
+func Text(text string) *Response {
+	resp := &Response{}
+	resp.Reply(text)
+	return resp
+}
+
+// NoReply creates a Response telling the hub not to reply.
+func NoReply() *Response {
+	return &Response{}
+}
+
+// Reply adds a message answering the invoker and returns it for further changes.
+func (resp *Response) Reply(text string) *Message {
+	return resp.add(text, TargetReply)
+}
+
+// Broadcast adds a message sent to the whole channel and returns it for further changes.
+func (resp *Response) Broadcast(text string) *Message {
+	return resp.add(text, TargetBroadcast)
+}
+
+func (resp *Response) add(text string, target Target) *Message {
+	msg := &Message{Text: text, Target: target}
+	resp.Messages = append(resp.Messages, msg)
+	return msg
+}
+
+// IsText reports if the response is a single reply consisting of text only,
+// so it can be sent as plain string without losing anything.
+func (resp *Response) IsText() bool {
+	return len(resp.Messages) == 1 && resp.Messages[0].isText()
+}
+
+// String joins the texts of all messages by newlines. It's used by
+// transports only able to send plain strings.
+func (resp *Response) String() string {
+	texts := make([]string, len(resp.Messages))
+	for i, msg := range resp.Messages {
+		texts[i] = msg.Text
+	}
+	return strings.Join(texts, "\n")
+}
+
+// RichCommand handles a command invocation like ContextCommand but returns
+// a structured Response instead of a string.
+type RichCommand interface {
+	HandleRich(ctx context.Context, args []string) (*Response, error)
+}
+
+// RichCommandFunc represents a RichCommand as function directly.
+type RichCommandFunc func(ctx context.Context, args []string) (*Response, error)
+
+// HandleRich calls the function itself.
+func (fn RichCommandFunc) HandleRich(ctx context.Context, args []string) (*Response, error) {
+	return fn(ctx, args)
+}
+
+// Rich adapts a ContextCommand to a RichCommand. Results are mapped to a
+// single text message. Commands already implementing RichCommand are
+// returned as is.
+func Rich(cmd ContextCommand) RichCommand {
+	if rich, ok := cmd.(RichCommand); ok {
+		return rich
+	}
+	return RichCommandFunc(func(ctx context.Context, args []string) (*Response, error) {
+		result, err := cmd.Handle(ctx, args)
+		if err != nil {
+			return nil, err
+		}
+		return Text(result), nil
+	})
+}
@@ -0,0 +1,154 @@
+package lib
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestResponse(t *testing.T) {
+	if resp := Text("Hello!"); !resp.IsText() || resp.String() != "Hello!" {
+		t.Errorf("invalid text response %+v", resp)
+	}
+	if resp := NoReply(); resp.IsText() || len(resp.Messages) != 0 {
+		t.Errorf("invalid no reply response %+v", resp)
+	}
+
+	resp := &Response{}
+	resp.Reply("Hello, Kevin!").Mention("42", "Kevin")
+	resp.Broadcast("Kevin joined").Embed(Embed{Title: "Kevin"})
+	if resp.IsText() {
+		t.Error("response with mentions and broadcasts isn't plain text")
+	}
+	if resp.String() != "Hello, Kevin!\nKevin joined" {
+		t.Errorf("invalid text '%s'", resp.String())
+	}
+	expected := []*Message{
+		{Text: "Hello, Kevin!", Target: TargetReply, Mentions: []Mention{{UserID: "42", Name: "Kevin"}}},
+		{Text: "Kevin joined", Target: TargetBroadcast, Embeds: []Embed{{Title: "Kevin"}}},
+	}
+	if !reflect.DeepEqual(resp.Messages, expected) {
+		t.Errorf("invalid messages %+v", resp.Messages)
+	}
+}
+
+func TestReaderWriterProvider_Rich(t *testing.T) {
+	handler := RichCommandFunc(func(ctx context.Context, args []string) (*Response, error) {
+		switch args[0] {
+		case "text":
+			return Text("plain"), nil
+		case "silent":
+			return NoReply(), nil
+		}
+		resp := &Response{}
+		resp.Reply("first").Mention("42", "Kevin")
+		resp.Broadcast("second")
+		return resp, nil
+	})
+	input := "{\"id\":1,\"args\":[\"text\"]}\n{\"id\":2,\"args\":[\"silent\"]}\n{\"id\":3,\"args\":[\"rich\"]}\n"
+
+	var out bytes.Buffer
+	provider := &ReaderWriterProvider{
+		Input:       strings.NewReader(input),
+		Output:      &out,
+		Framed:      true,
+		RichHandler: handler,
+	}
+	<-provider.Start()
+
+	scanner := bufio.NewScanner(&out)
+	var frames []*Frame
+	for scanner.Scan() {
+		frame, err := ParseFrame(scanner.Bytes())
+		if err != nil {
+			t.Fatal(err)
+		}
+		if frame.Type == "" {
+			frames = append(frames, frame)
+		}
+	}
+	if len(frames) != 3 {
+		t.Fatalf("expected 3 frames, got %d", len(frames))
+	}
+	if frames[0].Result != "plain" || frames[0].Response != nil {
+		t.Errorf("text isn't written as result: %+v", *frames[0])
+	}
+	if frames[1].Response == nil || len(frames[1].Response.Messages) != 0 {
+		t.Errorf("no reply isn't written as empty response: %+v", *frames[1])
+	}
+	if resp := frames[2].Response; resp == nil || len(resp.Messages) != 2 || resp.Messages[0].Mentions[0].UserID != "42" || resp.Messages[1].Target != TargetBroadcast {
+		t.Errorf("invalid rich response: %+v", *frames[2])
+	}
+
+	// Plain lines only carry the text
+	out.Reset()
+	provider = &ReaderWriterProvider{
+		Input:       strings.NewReader("silent\nrich\n"),
+		Output:      &out,
+		RichHandler: handler,
+	}
+	<-provider.Start()
+	if out.String() != "\nfirst second\n" {
+		t.Errorf("invalid plain output '%s'", out.String())
+	}
+}
+
+var countdown = RichCommandFunc(func(ctx context.Context, args []string) (*Response, error) {
+	for _, n := range []string{"3", "2", "1"} {
+		if err := SendChunk(ctx, Text(n)); err != nil {
+			return nil, err
+		}
+	}
+	return Text("Go!"), nil
+})
+
+func TestReaderWriterProvider_Chunks(t *testing.T) {
+	var out bytes.Buffer
+	provider := &ReaderWriterProvider{
+		Input:       strings.NewReader("{\"id\":1}\n"),
+		Output:      &out,
+		Framed:      true,
+		RichHandler: countdown,
+	}
+	<-provider.Start()
+
+	expected := []Frame{
+		{ID: 0, Type: FrameHello},
+		{ID: 1, Type: FrameChunk, Result: "3"},
+		{ID: 1, Type: FrameChunk, Result: "2"},
+		{ID: 1, Type: FrameChunk, Result: "1"},
+		{ID: 1, Result: "Go!"},
+	}
+	scanner := bufio.NewScanner(&out)
+	for _, exp := range expected {
+		if !scanner.Scan() {
+			t.Fatalf("missing frame %+v", exp)
+		}
+		frame, err := ParseFrame(scanner.Bytes())
+		if err != nil {
+			t.Fatal(err)
+		}
+		if frame.ID != exp.ID || frame.Type != exp.Type || frame.Result != exp.Result {
+			t.Errorf("invalid frame %+v, expected %+v", *frame, exp)
+		}
+	}
+
+	// Plain lines collect the chunks
+	out.Reset()
+	provider = &ReaderWriterProvider{
+		Input:       strings.NewReader("\n"),
+		Output:      &out,
+		RichHandler: countdown,
+	}
+	<-provider.Start()
+	if out.String() != "3 2 1 Go!\n" {
+		t.Errorf("invalid plain output '%s'", out.String())
+	}
+
+	if err := SendChunk(context.Background(), Text("lost")); err != ErrNoChunks {
+		t.Errorf("expected ErrNoChunks, got %v", err)
+	}
+}
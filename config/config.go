@@ -0,0 +1,445 @@
+// Package config reads the provider configuration of the hub. It lists the
+// providers with how to start and reach them, the commands they own and
+// the limits applied to them.
+package config
+
+import (
+	"fmt"
+	"io"
+	"io/ioutil"
+	"net/url"
+	"os"
+	"regexp"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/subcommands_test/cli/lib"
+	"github.com/subcommands_test/supervisor"
+	"gopkg.in/yaml.v2"
+)
+
+// Transport is the way the hub talks to a provider.
+type Transport string
+
+const (
+	// TransportCli exchanges plain lines with the stdin and stdout of the process.
+	TransportCli Transport = "cli"
+	// TransportCliFramed exchanges frames with the stdin and stdout of the process.
+	TransportCliFramed Transport = "cli-framed"
+	// TransportWeb sends http requests to Address.
+	TransportWeb Transport = "web"
+	// TransportGrpc calls the unary Handle rpc at Address.
+	TransportGrpc Transport = "grpc"
+	// TransportGrpcStream sends invocations over a single HandleStream rpc at Address.
+	TransportGrpcStream Transport = "grpc-stream"
+)
+
+// Stdio reports if the transport uses the stdin and stdout of the process.
+func (transport Transport) Stdio() bool {
+	return transport == TransportCli || transport == TransportCliFramed
+}
+
+// Restart policies of RestartConfig.
+const (
+	RestartNever     = "never"
+	RestartOnFailure = "on-failure"
+	RestartAlways    = "always"
+)
+
+// Config is the content of a config file.
+type Config struct {
+	Router    RouterConfig     `yaml:"router"`
+	Providers []ProviderConfig `yaml:"providers"`
+}
+
+// RouterConfig configures how chat messages are routed to the commands of
+// the providers, see hub.Router.
+type RouterConfig struct {
+	// Prefix of commands, e.g. '!'. Defaults to the one of the router.
+	Prefix string `yaml:"prefix"`
+	// Aliases maps alias names to command names.
+	Aliases map[string]string `yaml:"aliases"`
+	// Channels overrides the prefix and adds aliases per channel.
+	Channels map[string]ChannelConfig `yaml:"channels"`
+}
+
+// ChannelConfig overrides the router config in a single channel.
+type ChannelConfig struct {
+	Prefix  string            `yaml:"prefix"`
+	Aliases map[string]string `yaml:"aliases"`
+}
+
+// ProviderConfig configures a single provider.
+type ProviderConfig struct {
+	// Name identifies the provider, e.g. in logs and for events.
+	Name      string    `yaml:"name"`
+	Transport Transport `yaml:"transport"`
+
+	// Exec is the executable started by the hub. Required for the cli
+	// transports. Web and grpc providers without Exec run on their own.
+	Exec string            `yaml:"exec"`
+	Args []string          `yaml:"args"`
+	Env  map[string]string `yaml:"env"`
+	// Address of web and grpc providers, e.g. 'http://localhost:8080' or
+	// 'unix:///tmp/grpcprov.sock'. Web providers listening on a
+	// unix socket are dialed with 'unix:///tmp/web_subcommand.sock'.
+	Address string `yaml:"address"`
+
+	// Commands owned by the provider. Every command is owned by a single provider.
+	Commands []string `yaml:"commands"`
+	// Multi marks providers routing invocations by the command name, like
+	// the ones started with -multi, so the router passes the name even if
+	// they own a single command. Providers owning several commands always
+	// get it.
+	Multi bool `yaml:"multi"`
+	// Timeout of a single invocation enforced by the hub. Zero doesn't limit
+	// it. The cli transport doesn't support timeouts.
+	Timeout time.Duration `yaml:"timeout"`
+	// Timeouts overrides Timeout per command.
+	Timeouts map[string]time.Duration `yaml:"timeouts"`
+	Restart  RestartConfig            `yaml:"restart"`
+	Limits   LimitsConfig             `yaml:"limits"`
+	Web      WebConfig                `yaml:"web"`
+}
+
+// RestartConfig configures how crashed provider processes are restarted.
+type RestartConfig struct {
+	// Policy is one of 'never', 'on-failure' and 'always'. Defaults to
+	// 'on-failure', which doesn't restart processes exiting with status 0.
+	Policy string `yaml:"policy"`
+	// MaxRestarts limits the restarts with 'on-failure'. Defaults to 5.
+	MaxRestarts    int           `yaml:"max_restarts"`
+	InitialBackoff time.Duration `yaml:"initial_backoff"`
+	MaxBackoff     time.Duration `yaml:"max_backoff"`
+	ResetAfter     time.Duration `yaml:"reset_after"`
+}
+
+// LimitsConfig limits what a provider is allowed to do.
+type LimitsConfig struct {
+	// MaxInFlight limits the invocations running at the same time, further
+	// ones fail with CodeUnavailable. Zero doesn't limit them.
+	MaxInFlight int `yaml:"max_in_flight"`
+	// EventRate and EventBurst limit the events pushed by the provider, see
+	// hub.EventLimit. Events are refused unless EventBurst is set.
+	EventRate  float64 `yaml:"event_rate"`
+	EventBurst int     `yaml:"event_burst"`
+	// EventToken authenticates events pushed to the webhook of the hub.
+	EventToken string `yaml:"event_token"`
+}
+
+// WebConfig tunes the http client of web providers, see hub.WebOptions.
+type WebConfig struct {
+	// H2C sends cleartext HTTP/2, the provider has to accept it like
+	// webprov with -h2c.
+	H2C bool `yaml:"h2c"`
+	// MaxConns limits the HTTP/1.1 connections to the provider. Zero doesn't limit them.
+	MaxConns int `yaml:"max_conns"`
+	// MaxIdleConns is the number of keep-alive connections kept open. Defaults to 16.
+	MaxIdleConns int `yaml:"max_idle_conns"`
+	// IdleTimeout closes keep-alive connections idle for longer. Defaults to 90s.
+	IdleTimeout time.Duration `yaml:"idle_timeout"`
+}
+
+// Load reads and validates the config file.
+func Load(path string) (*Config, error) {
+	data, err := ioutil.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	cfg, err := Parse(data)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", path, err)
+	}
+	return cfg, nil
+}
+
+// Parse decodes and validates the YAML config. Unknown keys are refused to
+// catch typos.
+func Parse(data []byte) (*Config, error) {
+	var cfg Config
+	if err := yaml.UnmarshalStrict(data, &cfg); err != nil {
+		return nil, err
+	}
+	if err := cfg.Validate(); err != nil {
+		return nil, err
+	}
+	return &cfg, nil
+}
+
+// Provider returns the config of the provider with the name.
+func (cfg *Config) Provider(name string) (*ProviderConfig, bool) {
+	for i := range cfg.Providers {
+		if cfg.Providers[i].Name == name {
+			return &cfg.Providers[i], true
+		}
+	}
+	return nil, false
+}
+
+// ValidationError lists all problems found in a config.
+type ValidationError []string
+
+func (err ValidationError) Error() string {
+	return "invalid config:\n  " + strings.Join(err, "\n  ")
+}
+
+var validName = regexp.MustCompile(`^[a-zA-Z0-9_-]+$`)
+
+// Validate checks the config and returns a ValidationError with all
+// problems found.
+func (cfg *Config) Validate() error {
+	var problems ValidationError
+	names := make(map[string]bool)
+	owners := make(map[string]string)
+	// listeners maps the addresses of started providers to their names
+	listeners := make(map[string]string)
+	for i := range cfg.Providers {
+		prov := &cfg.Providers[i]
+		prefix := fmt.Sprintf("providers[%d]", i)
+		if prov.Name != "" {
+			prefix = fmt.Sprintf("%s (%s)", prefix, prov.Name)
+		}
+		for _, problem := range prov.validate() {
+			problems = append(problems, prefix+": "+problem)
+		}
+		if prov.Name != "" {
+			if names[prov.Name] {
+				problems = append(problems, fmt.Sprintf("%s: name '%s' is used by multiple providers", prefix, prov.Name))
+			}
+			names[prov.Name] = true
+		}
+		if prov.Exec != "" && !prov.Transport.Stdio() && prov.Address != "" {
+			address := prov.listenAddress()
+			if owner, ok := listeners[address]; ok {
+				problems = append(problems, fmt.Sprintf("%s: address '%s' is already used by %s", prefix, prov.Address, owner))
+			} else {
+				listeners[address] = prov.Name
+			}
+		}
+		for _, command := range prov.Commands {
+			command = strings.ToLower(command)
+			if owner, ok := owners[command]; ok && owner != prov.Name {
+				problems = append(problems, fmt.Sprintf("%s: command '%s' is already owned by %s", prefix, command, owner))
+				continue
+			}
+			owners[command] = prov.Name
+		}
+	}
+	problems = append(problems, cfg.Router.validate(owners)...)
+	if len(problems) > 0 {
+		return problems
+	}
+	return nil
+}
+
+func (router *RouterConfig) validate(owners map[string]string) []string {
+	var problems []string
+	check := func(prefix string, cmdPrefix string, aliases map[string]string) {
+		if strings.ContainsAny(cmdPrefix, " \t\n") {
+			problems = append(problems, fmt.Sprintf("%s: prefix '%s' must not contain whitespace", prefix, cmdPrefix))
+		}
+		names := make([]string, 0, len(aliases))
+		for alias := range aliases {
+			names = append(names, alias)
+		}
+		sort.Strings(names)
+		for _, alias := range names {
+			command := strings.ToLower(aliases[alias])
+			if alias == "" || strings.ContainsAny(alias, " \t\n") {
+				problems = append(problems, fmt.Sprintf("%s: invalid alias name '%s'", prefix, alias))
+			}
+			if owner, ok := owners[strings.ToLower(alias)]; ok {
+				problems = append(problems, fmt.Sprintf("%s: alias '%s' is already a command of %s", prefix, alias, owner))
+			}
+			if _, ok := owners[command]; !ok {
+				problems = append(problems, fmt.Sprintf("%s: alias '%s' refers to unknown command '%s'", prefix, alias, command))
+			}
+		}
+	}
+	check("router", router.Prefix, router.Aliases)
+	channels := make([]string, 0, len(router.Channels))
+	for channel := range router.Channels {
+		channels = append(channels, channel)
+	}
+	sort.Strings(channels)
+	for _, channel := range channels {
+		if channel == "" {
+			problems = append(problems, "router.channels: channel name is missing")
+		}
+		check(fmt.Sprintf("router.channels[%s]", channel), router.Channels[channel].Prefix, router.Channels[channel].Aliases)
+	}
+	return problems
+}
+
+func (prov *ProviderConfig) validate() []string {
+	var problems []string
+	problem := func(format string, a ...interface{}) {
+		problems = append(problems, fmt.Sprintf(format, a...))
+	}
+
+	if prov.Name == "" {
+		problem("name is missing")
+	} else if !validName.MatchString(prov.Name) {
+		problem("name '%s' may only contain letters, digits, '-' and '_'", prov.Name)
+	}
+
+	switch prov.Transport {
+	case TransportCli, TransportCliFramed:
+		if prov.Exec == "" {
+			problem("exec is required for transport %s", prov.Transport)
+		}
+		if prov.Address != "" {
+			problem("address isn't used by transport %s", prov.Transport)
+		}
+	case TransportWeb:
+		endpoint, err := url.Parse(prov.Address)
+		switch {
+		case err == nil && endpoint.Scheme == "unix":
+			if endpoint.Host != "" || endpoint.Path == "" {
+				problem("address '%s' isn't a unix socket like 'unix:///tmp/web.sock'", prov.Address)
+			}
+		case err != nil || (endpoint.Scheme != "http" && endpoint.Scheme != "https"):
+			problem("address '%s' isn't a http url", prov.Address)
+		case prov.Web.H2C && endpoint.Scheme != "http":
+			problem("web.h2c requires a http address")
+		}
+		if prov.Web.MaxConns < 0 || prov.Web.MaxIdleConns < 0 || prov.Web.IdleTimeout < 0 {
+			problem("web settings must not be negative")
+		}
+	case TransportGrpc, TransportGrpcStream:
+		if prov.Address == "" {
+			problem("address is required for transport %s", prov.Transport)
+		}
+	case "":
+		problem("transport is missing")
+	default:
+		problem("unknown transport '%s', expected one of cli, cli-framed, web, grpc, grpc-stream", prov.Transport)
+	}
+	if prov.Exec == "" && len(prov.Args) > 0 {
+		problem("args are set without exec")
+	}
+	if prov.Transport != TransportWeb && prov.Web != (WebConfig{}) {
+		problem("web settings aren't used by transport %s", prov.Transport)
+	}
+
+	for _, command := range prov.Commands {
+		if command == "" || strings.ContainsAny(command, " \t\n") {
+			problem("invalid command name '%s'", command)
+		}
+	}
+	if prov.Timeout < 0 {
+		problem("timeout must not be negative")
+	}
+	if prov.Transport == TransportCli && (prov.Timeout != 0 || len(prov.Timeouts) > 0) {
+		// A stuck command would block all following invocations anyway
+		problem("timeouts aren't supported by transport cli, which can't abandon an invocation, use cli-framed")
+	}
+	commands := make([]string, 0, len(prov.Timeouts))
+	for command := range prov.Timeouts {
+		commands = append(commands, command)
+	}
+	sort.Strings(commands)
+	for _, command := range commands {
+		if prov.Timeouts[command] < 0 {
+			problem("timeout of command '%s' must not be negative", command)
+		}
+	}
+
+	switch prov.Restart.Policy {
+	case "", RestartNever, RestartOnFailure, RestartAlways:
+	default:
+		problem("unknown restart policy '%s', expected one of never, on-failure, always", prov.Restart.Policy)
+	}
+	if prov.Restart.MaxRestarts < 0 {
+		problem("restart.max_restarts must not be negative")
+	}
+	if prov.Restart.InitialBackoff < 0 || prov.Restart.MaxBackoff < 0 || prov.Restart.ResetAfter < 0 {
+		problem("restart durations must not be negative")
+	}
+
+	if prov.Limits.MaxInFlight < 0 {
+		problem("limits.max_in_flight must not be negative")
+	}
+	if prov.Limits.EventRate < 0 || prov.Limits.EventBurst < 0 {
+		problem("event limits must not be negative")
+	}
+	if (prov.Limits.EventRate > 0 || prov.Limits.EventToken != "") && prov.Limits.EventBurst == 0 {
+		problem("limits.event_burst must be at least 1 if events are allowed")
+	}
+	return problems
+}
+
+// listenAddress returns the address the process of a web or grpc provider
+// listens on, so 'http://localhost:8080' and 'localhost:8080' are the same.
+func (prov *ProviderConfig) listenAddress() string {
+	if strings.HasPrefix(prov.Address, "unix:") {
+		return "unix:" + strings.TrimPrefix(strings.TrimPrefix(prov.Address, "unix:"), "//")
+	}
+	endpoint, err := url.Parse(prov.Address)
+	if err != nil || (endpoint.Scheme != "http" && endpoint.Scheme != "https") {
+		return prov.Address
+	}
+	if endpoint.Port() == "" {
+		if endpoint.Scheme == "https" {
+			return endpoint.Host + ":443"
+		}
+		return endpoint.Host + ":80"
+	}
+	return endpoint.Host
+}
+
+// Policy returns the restart policy of the supervisor.
+func (prov *ProviderConfig) Policy() supervisor.RestartPolicy {
+	policy := supervisor.RestartPolicy{
+		MaxRestarts:    prov.Restart.MaxRestarts,
+		InitialBackoff: prov.Restart.InitialBackoff,
+		MaxBackoff:     prov.Restart.MaxBackoff,
+		ResetAfter:     prov.Restart.ResetAfter,
+	}
+	switch prov.Restart.Policy {
+	case RestartNever:
+		policy.MaxRestarts = 0
+	case RestartAlways:
+		policy.MaxRestarts = -1
+	default:
+		if policy.MaxRestarts == 0 {
+			policy.MaxRestarts = 5
+		}
+		policy.OnlyOnFailure = true
+	}
+	return policy
+}
+
+// Environ returns the environment of the process: the one of the hub
+// extended by Env. It's nil without Env, which keeps the one of the hub.
+func (prov *ProviderConfig) Environ() []string {
+	if len(prov.Env) == 0 {
+		return nil
+	}
+	keys := make([]string, 0, len(prov.Env))
+	for key := range prov.Env {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	env := os.Environ()
+	for _, key := range keys {
+		env = append(env, key+"="+prov.Env[key])
+	}
+	return env
+}
+
+// Supervisor returns a supervisor starting the executable of the provider.
+// It waits for lib.ReadyLine and restarts crashed processes according to
+// the restart policy. Connect is left to the caller.
+func (prov *ProviderConfig) Supervisor(stderr io.Writer) *supervisor.Supervisor {
+	return &supervisor.Supervisor{
+		Name:      prov.Name,
+		Path:      prov.Exec,
+		Args:      prov.Args,
+		Env:       prov.Environ(),
+		Stderr:    stderr,
+		Policy:    prov.Policy(),
+		ReadyLine: lib.ReadyLine,
+	}
+}
@@ -0,0 +1,210 @@
+package config
+
+import (
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestParse(t *testing.T) {
+	cfg, err := Parse([]byte(`
+providers:
+  - name: cliprov
+    transport: cli-framed
+    exec: build/cliprov
+    args: ["-framed"]
+    env:
+      LANG: C
+    commands: [hello, echo]
+    timeout: 5s
+    timeouts:
+      roll: 1m
+    restart:
+      policy: always
+      initial_backoff: 100ms
+    limits:
+      max_in_flight: 4
+      event_rate: 1
+      event_burst: 5
+      event_token: secret
+  - name: grpcprov
+    transport: grpc
+    address: localhost:8080
+    restart:
+      policy: never
+`))
+	if err != nil {
+		t.Fatal(err)
+	}
+	prov, ok := cfg.Provider("cliprov")
+	if !ok {
+		t.Fatal("cliprov missing")
+	}
+	if prov.Timeout != 5*time.Second || prov.Timeouts["roll"] != time.Minute || len(prov.Commands) != 2 || prov.Limits.EventBurst != 5 {
+		t.Errorf("invalid provider %+v", *prov)
+	}
+	if policy := prov.Policy(); policy.MaxRestarts != -1 || policy.InitialBackoff != 100*time.Millisecond || policy.OnlyOnFailure {
+		t.Errorf("invalid policy %+v", policy)
+	}
+	if env := prov.Environ(); env[len(env)-1] != "LANG=C" {
+		t.Errorf("invalid env %v", env)
+	}
+	prov, _ = cfg.Provider("grpcprov")
+	if policy := prov.Policy(); policy.MaxRestarts != 0 {
+		t.Errorf("expected no restarts, got %+v", policy)
+	}
+	if env := prov.Environ(); env != nil {
+		t.Errorf("expected environment of the hub, got %v", env)
+	}
+	prov.Restart.Policy = RestartOnFailure
+	if policy := prov.Policy(); policy.MaxRestarts != 5 || !policy.OnlyOnFailure {
+		t.Errorf("invalid on-failure policy %+v", policy)
+	}
+}
+
+func TestParse_UnknownKey(t *testing.T) {
+	_, err := Parse([]byte(`
+providers:
+  - name: cliprov
+    transport: cli
+    exec: build/cliprov
+    timout: 5s
+`))
+	if err == nil || !strings.Contains(err.Error(), "timout") {
+		t.Errorf("expected error for unknown key, got %v", err)
+	}
+}
+
+func TestValidate(t *testing.T) {
+	_, err := Parse([]byte(`
+providers:
+  - name: cliprov
+    transport: cli
+    commands: [hello]
+    timeout: 1s
+    web:
+      h2c: true
+  - name: webprov
+    transport: web
+    address: localhost:8080
+    commands: [Hello]
+    web:
+      max_conns: -1
+  - name: webprov
+    transport: smoke-signals
+    restart:
+      policy: sometimes
+    limits:
+      event_rate: 1
+  - name: websocket
+    transport: web
+    address: unix://web.sock
+    limits:
+      event_token: secret
+`))
+	verr, ok := err.(ValidationError)
+	if !ok {
+		t.Fatalf("expected ValidationError, got %v", err)
+	}
+	expected := []string{
+		"providers[0] (cliprov): exec is required for transport cli",
+		"providers[0] (cliprov): web settings aren't used by transport cli",
+		"providers[0] (cliprov): timeouts aren't supported by transport cli, which can't abandon an invocation, use cli-framed",
+		"providers[1] (webprov): address 'localhost:8080' isn't a http url",
+		"providers[1] (webprov): web settings must not be negative",
+		"providers[1] (webprov): command 'hello' is already owned by cliprov",
+		"providers[2] (webprov): unknown transport 'smoke-signals', expected one of cli, cli-framed, web, grpc, grpc-stream",
+		"providers[2] (webprov): unknown restart policy 'sometimes', expected one of never, on-failure, always",
+		"providers[2] (webprov): limits.event_burst must be at least 1 if events are allowed",
+		"providers[2] (webprov): name 'webprov' is used by multiple providers",
+		"providers[3] (websocket): address 'unix://web.sock' isn't a unix socket like 'unix:///tmp/web.sock'",
+		"providers[3] (websocket): limits.event_burst must be at least 1 if events are allowed",
+	}
+	if strings.Join(verr, "\n") != strings.Join(expected, "\n") {
+		t.Errorf("invalid problems:\n%s", strings.Join(verr, "\n"))
+	}
+}
+
+func TestValidate_Address(t *testing.T) {
+	_, err := Parse([]byte(`
+providers:
+  - name: webprov
+    transport: web
+    exec: build/webprov
+    address: http://localhost:8080
+  - name: grpcprov
+    transport: grpc
+    exec: build/grpcprov
+    address: localhost:8080
+  - name: webprov-remote
+    transport: web
+    address: http://localhost:8080
+  - name: webprov-socket
+    transport: web
+    exec: build/webprov
+    address: unix:///tmp/prov.sock
+  - name: grpcprov-socket
+    transport: grpc
+    exec: build/grpcprov
+    address: unix:/tmp/prov.sock
+`))
+	verr, ok := err.(ValidationError)
+	if !ok {
+		t.Fatalf("expected ValidationError, got %v", err)
+	}
+	// Providers running on their own may share the address of a started one
+	expected := []string{
+		"providers[1] (grpcprov): address 'localhost:8080' is already used by webprov",
+		"providers[4] (grpcprov-socket): address 'unix:/tmp/prov.sock' is already used by webprov-socket",
+	}
+	if strings.Join(verr, "\n") != strings.Join(expected, "\n") {
+		t.Errorf("invalid problems:\n%s", strings.Join(verr, "\n"))
+	}
+}
+
+func TestLoad(t *testing.T) {
+	cfg, err := Load("../providers.yaml")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, ok := cfg.Provider("cliprov"); !ok {
+		t.Error("cliprov missing")
+	}
+	if _, err := Load("missing.yaml"); err == nil {
+		t.Error("expected error for missing file")
+	}
+}
+
+func TestValidate_Router(t *testing.T) {
+	_, err := Parse([]byte(`
+router:
+  prefix: "! "
+  aliases:
+    hi: hello
+    echo: hello
+    roll: dice
+  channels:
+    "#dice":
+      prefix: "?"
+      aliases:
+        d: dice
+providers:
+  - name: cliprov
+    transport: cli
+    exec: build/cliprov
+    commands: [hello, echo]
+`))
+	verr, ok := err.(ValidationError)
+	if !ok {
+		t.Fatalf("expected ValidationError, got %v", err)
+	}
+	expected := []string{
+		"router: prefix '! ' must not contain whitespace",
+		"router: alias 'echo' is already a command of cliprov",
+		"router: alias 'roll' refers to unknown command 'dice'",
+		"router.channels[#dice]: alias 'd' refers to unknown command 'dice'",
+	}
+	if strings.Join(verr, "\n") != strings.Join(expected, "\n") {
+		t.Errorf("invalid problems:\n%s", strings.Join(verr, "\n"))
+	}
+}
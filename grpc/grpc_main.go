@@ -1,65 +1,60 @@
 package main
 
 import (
+	"context"
 	"flag"
 	"log"
-	"net"
 	"os"
-	"os/signal"
-	"syscall"
+	"strconv"
 	"time"
 
-	"github.com/subcommands_test/grpc/pb"
+	"github.com/subcommands_test/cli/lib"
 	"github.com/subcommands_test/grpc/provider"
-	"google.golang.org/grpc"
 )
 
 func main() {
 	network := flag.String("network", "unix", "Network to use. Either 'unix' or 'tcp'. Default is unix")
-	address := flag.String("address", "/tmp/grpc_subcommand.sock", "address to listen to. default is '/tmp/grpc_subcommand.sock'")
+	address := flag.String("address", "", "address to listen to. default is '/tmp/<name>.sock' for unix and ':8080' for tcp")
+	name := flag.String("name", "grpcprov", "Name of the provider in its manifest, which derives the default socket path")
+	socketMode := flag.String("socket-mode", "", "File mode of the unix socket, e.g. '0600' so only its owner can connect")
+	socketOwner := flag.String("socket-owner", "", "User owning the unix socket, e.g. the user of the hub")
+	socketGroup := flag.String("socket-group", "", "Group owning the unix socket")
+	multi := flag.Bool("multi", false, "Provide all commands of the lib package instead of hello only")
+	gracePeriod := flag.Duration("grace-period", 5*time.Second, "Time in-flight invocations have to finish on shutdown")
+	announce := flag.Duration("announce", 0, "Push an announcement event to subscribed hubs in this interval")
 
 	flag.Parse()
 
-	lis, err := net.Listen(*network, *address)
-	if err != nil {
-		log.Fatalf("failed to listen: %v", err)
-	}
-	grpcServer := grpc.NewServer()
-	pb.RegisterCommandServer(grpcServer, &provider.CommandProviderServer{})
-
-	waitc := make(chan struct{})
-	go func() {
-		defer close(waitc)
-		err := grpcServer.Serve(lis)
-		if err == grpc.ErrServerStopped {
-			return
+	var mode uint64
+	if *socketMode != "" {
+		var err error
+		if mode, err = strconv.ParseUint(*socketMode, 8, 32); err != nil {
+			log.Fatalf("invalid socket mode '%s': %v", *socketMode, err)
 		}
-		if err != nil {
-			log.Fatal(err)
-		}
-	}()
-
-	sigs := make(chan os.Signal)
-	waitsig := make(chan struct{})
-
-	signal.Notify(sigs, os.Interrupt, syscall.SIGINT, syscall.SIGTERM, syscall.SIGKILL)
+	}
 
-	go func() {
-		sig := <-sigs
-		log.Printf("Signal received: %v\n", sig)
-		close(waitsig)
-	}()
+	server := &provider.CommandProviderServer{
+		Manifest:    lib.NewManifest(*name, lib.Version, lib.HelloInfo),
+		HandlerFunc: lib.HelloProvider,
+	}
+	if *multi {
+		reg := lib.DefaultRegistry()
+		server.RichHandler = reg
+		server.Manifest.Commands = reg.Describe()
+	}
+	if *announce > 0 {
+		go lib.Announce(context.Background(), *announce, *name+" is still running", server.Push)
+	}
 
-	select {
-	case <-waitc:
-		// Server has been closed for any reason
-	case <-waitsig:
-		// Signal received, server has to be closed now
-		grpcServer.Stop()
-		select {
-		case <-waitc:
-		case <-time.After(time.Second):
-			log.Fatal("server wasn't closed after stop")
-		}
+	err := provider.Serve(context.Background(), server, provider.Options{
+		Network:     *network,
+		Address:     *address,
+		SocketMode:  os.FileMode(mode),
+		SocketOwner: *socketOwner,
+		SocketGroup: *socketGroup,
+		GracePeriod: *gracePeriod,
+	})
+	if err != nil {
+		log.Fatal(err)
 	}
 }
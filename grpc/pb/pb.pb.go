@@ -7,9 +7,11 @@ import (
 	context "context"
 	fmt "fmt"
 	proto "github.com/golang/protobuf/proto"
+	timestamp "github.com/golang/protobuf/ptypes/timestamp"
+	status "google.golang.org/genproto/googleapis/rpc/status"
 	grpc "google.golang.org/grpc"
 	codes "google.golang.org/grpc/codes"
-	status "google.golang.org/grpc/status"
+	status1 "google.golang.org/grpc/status"
 	math "math"
 )
 
@@ -24,11 +26,41 @@ var _ = math.Inf
 // proto package needs to be updated.
 const _ = proto.ProtoPackageIsVersion3 // please upgrade the proto package
 
+type Message_Target int32
+
+const (
+	Message_REPLY     Message_Target = 0
+	Message_BROADCAST Message_Target = 1
+)
+
+var Message_Target_name = map[int32]string{
+	0: "REPLY",
+	1: "BROADCAST",
+}
+
+var Message_Target_value = map[string]int32{
+	"REPLY":     0,
+	"BROADCAST": 1,
+}
+
+func (x Message_Target) String() string {
+	return proto.EnumName(Message_Target_name, int32(x))
+}
+
+func (Message_Target) EnumDescriptor() ([]byte, []int) {
+	return fileDescriptor_f80abaa17e25ccc8, []int{4, 0}
+}
+
 type CommandArguments struct {
-	Args                 []string `protobuf:"bytes,1,rep,name=args,proto3" json:"args,omitempty"`
-	XXX_NoUnkeyedLiteral struct{} `json:"-"`
-	XXX_unrecognized     []byte   `json:"-"`
-	XXX_sizecache        int32    `json:"-"`
+	Args []string `protobuf:"bytes,1,rep,name=args,proto3" json:"args,omitempty"`
+	// Name of the command to invoke on providers with multiple commands.
+	// It's passed to the provider as first argument if set.
+	CommandName string `protobuf:"bytes,2,opt,name=command_name,json=commandName,proto3" json:"command_name,omitempty"`
+	// Describes who invoked the command. Optional.
+	Invocation           *Invocation `protobuf:"bytes,3,opt,name=invocation,proto3" json:"invocation,omitempty"`
+	XXX_NoUnkeyedLiteral struct{}    `json:"-"`
+	XXX_unrecognized     []byte      `json:"-"`
+	XXX_sizecache        int32       `json:"-"`
 }
 
 func (m *CommandArguments) Reset()         { *m = CommandArguments{} }
@@ -63,8 +95,118 @@ func (m *CommandArguments) GetArgs() []string {
 	return nil
 }
 
+func (m *CommandArguments) GetCommandName() string {
+	if m != nil {
+		return m.CommandName
+	}
+	return ""
+}
+
+func (m *CommandArguments) GetInvocation() *Invocation {
+	if m != nil {
+		return m.Invocation
+	}
+	return nil
+}
+
+type Invocation struct {
+	SenderId             string               `protobuf:"bytes,1,opt,name=sender_id,json=senderId,proto3" json:"sender_id,omitempty"`
+	SenderName           string               `protobuf:"bytes,2,opt,name=sender_name,json=senderName,proto3" json:"sender_name,omitempty"`
+	Channel              string               `protobuf:"bytes,3,opt,name=channel,proto3" json:"channel,omitempty"`
+	Platform             string               `protobuf:"bytes,4,opt,name=platform,proto3" json:"platform,omitempty"`
+	Roles                []string             `protobuf:"bytes,5,rep,name=roles,proto3" json:"roles,omitempty"`
+	MessageId            string               `protobuf:"bytes,6,opt,name=message_id,json=messageId,proto3" json:"message_id,omitempty"`
+	Timestamp            *timestamp.Timestamp `protobuf:"bytes,7,opt,name=timestamp,proto3" json:"timestamp,omitempty"`
+	XXX_NoUnkeyedLiteral struct{}             `json:"-"`
+	XXX_unrecognized     []byte               `json:"-"`
+	XXX_sizecache        int32                `json:"-"`
+}
+
+func (m *Invocation) Reset()         { *m = Invocation{} }
+func (m *Invocation) String() string { return proto.CompactTextString(m) }
+func (*Invocation) ProtoMessage()    {}
+func (*Invocation) Descriptor() ([]byte, []int) {
+	return fileDescriptor_f80abaa17e25ccc8, []int{1}
+}
+
+func (m *Invocation) XXX_Unmarshal(b []byte) error {
+	return xxx_messageInfo_Invocation.Unmarshal(m, b)
+}
+func (m *Invocation) XXX_Marshal(b []byte, deterministic bool) ([]byte, error) {
+	return xxx_messageInfo_Invocation.Marshal(b, m, deterministic)
+}
+func (m *Invocation) XXX_Merge(src proto.Message) {
+	xxx_messageInfo_Invocation.Merge(m, src)
+}
+func (m *Invocation) XXX_Size() int {
+	return xxx_messageInfo_Invocation.Size(m)
+}
+func (m *Invocation) XXX_DiscardUnknown() {
+	xxx_messageInfo_Invocation.DiscardUnknown(m)
+}
+
+var xxx_messageInfo_Invocation proto.InternalMessageInfo
+
+func (m *Invocation) GetSenderId() string {
+	if m != nil {
+		return m.SenderId
+	}
+	return ""
+}
+
+func (m *Invocation) GetSenderName() string {
+	if m != nil {
+		return m.SenderName
+	}
+	return ""
+}
+
+func (m *Invocation) GetChannel() string {
+	if m != nil {
+		return m.Channel
+	}
+	return ""
+}
+
+func (m *Invocation) GetPlatform() string {
+	if m != nil {
+		return m.Platform
+	}
+	return ""
+}
+
+func (m *Invocation) GetRoles() []string {
+	if m != nil {
+		return m.Roles
+	}
+	return nil
+}
+
+func (m *Invocation) GetMessageId() string {
+	if m != nil {
+		return m.MessageId
+	}
+	return ""
+}
+
+func (m *Invocation) GetTimestamp() *timestamp.Timestamp {
+	if m != nil {
+		return m.Timestamp
+	}
+	return nil
+}
+
 type CommandResult struct {
-	Result               string   `protobuf:"bytes,1,opt,name=result,proto3" json:"result,omitempty"`
+	// Text of all messages of the response.
+	Result string `protobuf:"bytes,1,opt,name=result,proto3" json:"result,omitempty"`
+	// Set if the command failed. Only used by HandleStream, as Handle returns the status as error.
+	Status *status.Status `protobuf:"bytes,2,opt,name=status,proto3" json:"status,omitempty"`
+	// Structured response. Only set if the response isn't a single text reply,
+	// which is sent as result only.
+	Response *Response `protobuf:"bytes,3,opt,name=response,proto3" json:"response,omitempty"`
+	// Set for chunks of the response sent by HandleStream before the result,
+	// which is sent without chunk to mark the end.
+	Chunk                bool     `protobuf:"varint,4,opt,name=chunk,proto3" json:"chunk,omitempty"`
 	XXX_NoUnkeyedLiteral struct{} `json:"-"`
 	XXX_unrecognized     []byte   `json:"-"`
 	XXX_sizecache        int32    `json:"-"`
@@ -74,7 +216,7 @@ func (m *CommandResult) Reset()         { *m = CommandResult{} }
 func (m *CommandResult) String() string { return proto.CompactTextString(m) }
 func (*CommandResult) ProtoMessage()    {}
 func (*CommandResult) Descriptor() ([]byte, []int) {
-	return fileDescriptor_f80abaa17e25ccc8, []int{1}
+	return fileDescriptor_f80abaa17e25ccc8, []int{2}
 }
 
 func (m *CommandResult) XXX_Unmarshal(b []byte) error {
@@ -102,25 +244,620 @@ func (m *CommandResult) GetResult() string {
 	return ""
 }
 
+func (m *CommandResult) GetStatus() *status.Status {
+	if m != nil {
+		return m.Status
+	}
+	return nil
+}
+
+func (m *CommandResult) GetResponse() *Response {
+	if m != nil {
+		return m.Response
+	}
+	return nil
+}
+
+func (m *CommandResult) GetChunk() bool {
+	if m != nil {
+		return m.Chunk
+	}
+	return false
+}
+
+// Response consists of the messages sent in order. Without messages the hub doesn't reply.
+type Response struct {
+	Messages             []*Message `protobuf:"bytes,1,rep,name=messages,proto3" json:"messages,omitempty"`
+	XXX_NoUnkeyedLiteral struct{}   `json:"-"`
+	XXX_unrecognized     []byte     `json:"-"`
+	XXX_sizecache        int32      `json:"-"`
+}
+
+func (m *Response) Reset()         { *m = Response{} }
+func (m *Response) String() string { return proto.CompactTextString(m) }
+func (*Response) ProtoMessage()    {}
+func (*Response) Descriptor() ([]byte, []int) {
+	return fileDescriptor_f80abaa17e25ccc8, []int{3}
+}
+
+func (m *Response) XXX_Unmarshal(b []byte) error {
+	return xxx_messageInfo_Response.Unmarshal(m, b)
+}
+func (m *Response) XXX_Marshal(b []byte, deterministic bool) ([]byte, error) {
+	return xxx_messageInfo_Response.Marshal(b, m, deterministic)
+}
+func (m *Response) XXX_Merge(src proto.Message) {
+	xxx_messageInfo_Response.Merge(m, src)
+}
+func (m *Response) XXX_Size() int {
+	return xxx_messageInfo_Response.Size(m)
+}
+func (m *Response) XXX_DiscardUnknown() {
+	xxx_messageInfo_Response.DiscardUnknown(m)
+}
+
+var xxx_messageInfo_Response proto.InternalMessageInfo
+
+func (m *Response) GetMessages() []*Message {
+	if m != nil {
+		return m.Messages
+	}
+	return nil
+}
+
+type Message struct {
+	Text                 string         `protobuf:"bytes,1,opt,name=text,proto3" json:"text,omitempty"`
+	Target               Message_Target `protobuf:"varint,2,opt,name=target,proto3,enum=Message_Target" json:"target,omitempty"`
+	Mentions             []*Mention     `protobuf:"bytes,3,rep,name=mentions,proto3" json:"mentions,omitempty"`
+	Embeds               []*Embed       `protobuf:"bytes,4,rep,name=embeds,proto3" json:"embeds,omitempty"`
+	Attachments          []*Attachment  `protobuf:"bytes,5,rep,name=attachments,proto3" json:"attachments,omitempty"`
+	XXX_NoUnkeyedLiteral struct{}       `json:"-"`
+	XXX_unrecognized     []byte         `json:"-"`
+	XXX_sizecache        int32          `json:"-"`
+}
+
+func (m *Message) Reset()         { *m = Message{} }
+func (m *Message) String() string { return proto.CompactTextString(m) }
+func (*Message) ProtoMessage()    {}
+func (*Message) Descriptor() ([]byte, []int) {
+	return fileDescriptor_f80abaa17e25ccc8, []int{4}
+}
+
+func (m *Message) XXX_Unmarshal(b []byte) error {
+	return xxx_messageInfo_Message.Unmarshal(m, b)
+}
+func (m *Message) XXX_Marshal(b []byte, deterministic bool) ([]byte, error) {
+	return xxx_messageInfo_Message.Marshal(b, m, deterministic)
+}
+func (m *Message) XXX_Merge(src proto.Message) {
+	xxx_messageInfo_Message.Merge(m, src)
+}
+func (m *Message) XXX_Size() int {
+	return xxx_messageInfo_Message.Size(m)
+}
+func (m *Message) XXX_DiscardUnknown() {
+	xxx_messageInfo_Message.DiscardUnknown(m)
+}
+
+var xxx_messageInfo_Message proto.InternalMessageInfo
+
+func (m *Message) GetText() string {
+	if m != nil {
+		return m.Text
+	}
+	return ""
+}
+
+func (m *Message) GetTarget() Message_Target {
+	if m != nil {
+		return m.Target
+	}
+	return Message_REPLY
+}
+
+func (m *Message) GetMentions() []*Mention {
+	if m != nil {
+		return m.Mentions
+	}
+	return nil
+}
+
+func (m *Message) GetEmbeds() []*Embed {
+	if m != nil {
+		return m.Embeds
+	}
+	return nil
+}
+
+func (m *Message) GetAttachments() []*Attachment {
+	if m != nil {
+		return m.Attachments
+	}
+	return nil
+}
+
+type Mention struct {
+	UserId               string   `protobuf:"bytes,1,opt,name=user_id,json=userId,proto3" json:"user_id,omitempty"`
+	Name                 string   `protobuf:"bytes,2,opt,name=name,proto3" json:"name,omitempty"`
+	XXX_NoUnkeyedLiteral struct{} `json:"-"`
+	XXX_unrecognized     []byte   `json:"-"`
+	XXX_sizecache        int32    `json:"-"`
+}
+
+func (m *Mention) Reset()         { *m = Mention{} }
+func (m *Mention) String() string { return proto.CompactTextString(m) }
+func (*Mention) ProtoMessage()    {}
+func (*Mention) Descriptor() ([]byte, []int) {
+	return fileDescriptor_f80abaa17e25ccc8, []int{5}
+}
+
+func (m *Mention) XXX_Unmarshal(b []byte) error {
+	return xxx_messageInfo_Mention.Unmarshal(m, b)
+}
+func (m *Mention) XXX_Marshal(b []byte, deterministic bool) ([]byte, error) {
+	return xxx_messageInfo_Mention.Marshal(b, m, deterministic)
+}
+func (m *Mention) XXX_Merge(src proto.Message) {
+	xxx_messageInfo_Mention.Merge(m, src)
+}
+func (m *Mention) XXX_Size() int {
+	return xxx_messageInfo_Mention.Size(m)
+}
+func (m *Mention) XXX_DiscardUnknown() {
+	xxx_messageInfo_Mention.DiscardUnknown(m)
+}
+
+var xxx_messageInfo_Mention proto.InternalMessageInfo
+
+func (m *Mention) GetUserId() string {
+	if m != nil {
+		return m.UserId
+	}
+	return ""
+}
+
+func (m *Mention) GetName() string {
+	if m != nil {
+		return m.Name
+	}
+	return ""
+}
+
+type Embed struct {
+	Title                string   `protobuf:"bytes,1,opt,name=title,proto3" json:"title,omitempty"`
+	Description          string   `protobuf:"bytes,2,opt,name=description,proto3" json:"description,omitempty"`
+	Url                  string   `protobuf:"bytes,3,opt,name=url,proto3" json:"url,omitempty"`
+	ImageUrl             string   `protobuf:"bytes,4,opt,name=image_url,json=imageUrl,proto3" json:"image_url,omitempty"`
+	XXX_NoUnkeyedLiteral struct{} `json:"-"`
+	XXX_unrecognized     []byte   `json:"-"`
+	XXX_sizecache        int32    `json:"-"`
+}
+
+func (m *Embed) Reset()         { *m = Embed{} }
+func (m *Embed) String() string { return proto.CompactTextString(m) }
+func (*Embed) ProtoMessage()    {}
+func (*Embed) Descriptor() ([]byte, []int) {
+	return fileDescriptor_f80abaa17e25ccc8, []int{6}
+}
+
+func (m *Embed) XXX_Unmarshal(b []byte) error {
+	return xxx_messageInfo_Embed.Unmarshal(m, b)
+}
+func (m *Embed) XXX_Marshal(b []byte, deterministic bool) ([]byte, error) {
+	return xxx_messageInfo_Embed.Marshal(b, m, deterministic)
+}
+func (m *Embed) XXX_Merge(src proto.Message) {
+	xxx_messageInfo_Embed.Merge(m, src)
+}
+func (m *Embed) XXX_Size() int {
+	return xxx_messageInfo_Embed.Size(m)
+}
+func (m *Embed) XXX_DiscardUnknown() {
+	xxx_messageInfo_Embed.DiscardUnknown(m)
+}
+
+var xxx_messageInfo_Embed proto.InternalMessageInfo
+
+func (m *Embed) GetTitle() string {
+	if m != nil {
+		return m.Title
+	}
+	return ""
+}
+
+func (m *Embed) GetDescription() string {
+	if m != nil {
+		return m.Description
+	}
+	return ""
+}
+
+func (m *Embed) GetUrl() string {
+	if m != nil {
+		return m.Url
+	}
+	return ""
+}
+
+func (m *Embed) GetImageUrl() string {
+	if m != nil {
+		return m.ImageUrl
+	}
+	return ""
+}
+
+// Attachment is either referred to by url or sent along as data.
+type Attachment struct {
+	Name                 string   `protobuf:"bytes,1,opt,name=name,proto3" json:"name,omitempty"`
+	ContentType          string   `protobuf:"bytes,2,opt,name=content_type,json=contentType,proto3" json:"content_type,omitempty"`
+	Url                  string   `protobuf:"bytes,3,opt,name=url,proto3" json:"url,omitempty"`
+	Data                 []byte   `protobuf:"bytes,4,opt,name=data,proto3" json:"data,omitempty"`
+	XXX_NoUnkeyedLiteral struct{} `json:"-"`
+	XXX_unrecognized     []byte   `json:"-"`
+	XXX_sizecache        int32    `json:"-"`
+}
+
+func (m *Attachment) Reset()         { *m = Attachment{} }
+func (m *Attachment) String() string { return proto.CompactTextString(m) }
+func (*Attachment) ProtoMessage()    {}
+func (*Attachment) Descriptor() ([]byte, []int) {
+	return fileDescriptor_f80abaa17e25ccc8, []int{7}
+}
+
+func (m *Attachment) XXX_Unmarshal(b []byte) error {
+	return xxx_messageInfo_Attachment.Unmarshal(m, b)
+}
+func (m *Attachment) XXX_Marshal(b []byte, deterministic bool) ([]byte, error) {
+	return xxx_messageInfo_Attachment.Marshal(b, m, deterministic)
+}
+func (m *Attachment) XXX_Merge(src proto.Message) {
+	xxx_messageInfo_Attachment.Merge(m, src)
+}
+func (m *Attachment) XXX_Size() int {
+	return xxx_messageInfo_Attachment.Size(m)
+}
+func (m *Attachment) XXX_DiscardUnknown() {
+	xxx_messageInfo_Attachment.DiscardUnknown(m)
+}
+
+var xxx_messageInfo_Attachment proto.InternalMessageInfo
+
+func (m *Attachment) GetName() string {
+	if m != nil {
+		return m.Name
+	}
+	return ""
+}
+
+func (m *Attachment) GetContentType() string {
+	if m != nil {
+		return m.ContentType
+	}
+	return ""
+}
+
+func (m *Attachment) GetUrl() string {
+	if m != nil {
+		return m.Url
+	}
+	return ""
+}
+
+func (m *Attachment) GetData() []byte {
+	if m != nil {
+		return m.Data
+	}
+	return nil
+}
+
+type DescribeRequest struct {
+	XXX_NoUnkeyedLiteral struct{} `json:"-"`
+	XXX_unrecognized     []byte   `json:"-"`
+	XXX_sizecache        int32    `json:"-"`
+}
+
+func (m *DescribeRequest) Reset()         { *m = DescribeRequest{} }
+func (m *DescribeRequest) String() string { return proto.CompactTextString(m) }
+func (*DescribeRequest) ProtoMessage()    {}
+func (*DescribeRequest) Descriptor() ([]byte, []int) {
+	return fileDescriptor_f80abaa17e25ccc8, []int{8}
+}
+
+func (m *DescribeRequest) XXX_Unmarshal(b []byte) error {
+	return xxx_messageInfo_DescribeRequest.Unmarshal(m, b)
+}
+func (m *DescribeRequest) XXX_Marshal(b []byte, deterministic bool) ([]byte, error) {
+	return xxx_messageInfo_DescribeRequest.Marshal(b, m, deterministic)
+}
+func (m *DescribeRequest) XXX_Merge(src proto.Message) {
+	xxx_messageInfo_DescribeRequest.Merge(m, src)
+}
+func (m *DescribeRequest) XXX_Size() int {
+	return xxx_messageInfo_DescribeRequest.Size(m)
+}
+func (m *DescribeRequest) XXX_DiscardUnknown() {
+	xxx_messageInfo_DescribeRequest.DiscardUnknown(m)
+}
+
+var xxx_messageInfo_DescribeRequest proto.InternalMessageInfo
+
+type Manifest struct {
+	Name                 string         `protobuf:"bytes,1,opt,name=name,proto3" json:"name,omitempty"`
+	Version              string         `protobuf:"bytes,2,opt,name=version,proto3" json:"version,omitempty"`
+	ProtocolVersion      int32          `protobuf:"varint,3,opt,name=protocol_version,json=protocolVersion,proto3" json:"protocol_version,omitempty"`
+	Commands             []*CommandInfo `protobuf:"bytes,4,rep,name=commands,proto3" json:"commands,omitempty"`
+	XXX_NoUnkeyedLiteral struct{}       `json:"-"`
+	XXX_unrecognized     []byte         `json:"-"`
+	XXX_sizecache        int32          `json:"-"`
+}
+
+func (m *Manifest) Reset()         { *m = Manifest{} }
+func (m *Manifest) String() string { return proto.CompactTextString(m) }
+func (*Manifest) ProtoMessage()    {}
+func (*Manifest) Descriptor() ([]byte, []int) {
+	return fileDescriptor_f80abaa17e25ccc8, []int{9}
+}
+
+func (m *Manifest) XXX_Unmarshal(b []byte) error {
+	return xxx_messageInfo_Manifest.Unmarshal(m, b)
+}
+func (m *Manifest) XXX_Marshal(b []byte, deterministic bool) ([]byte, error) {
+	return xxx_messageInfo_Manifest.Marshal(b, m, deterministic)
+}
+func (m *Manifest) XXX_Merge(src proto.Message) {
+	xxx_messageInfo_Manifest.Merge(m, src)
+}
+func (m *Manifest) XXX_Size() int {
+	return xxx_messageInfo_Manifest.Size(m)
+}
+func (m *Manifest) XXX_DiscardUnknown() {
+	xxx_messageInfo_Manifest.DiscardUnknown(m)
+}
+
+var xxx_messageInfo_Manifest proto.InternalMessageInfo
+
+func (m *Manifest) GetName() string {
+	if m != nil {
+		return m.Name
+	}
+	return ""
+}
+
+func (m *Manifest) GetVersion() string {
+	if m != nil {
+		return m.Version
+	}
+	return ""
+}
+
+func (m *Manifest) GetProtocolVersion() int32 {
+	if m != nil {
+		return m.ProtocolVersion
+	}
+	return 0
+}
+
+func (m *Manifest) GetCommands() []*CommandInfo {
+	if m != nil {
+		return m.Commands
+	}
+	return nil
+}
+
+type CommandInfo struct {
+	Name                 string   `protobuf:"bytes,1,opt,name=name,proto3" json:"name,omitempty"`
+	Usage                string   `protobuf:"bytes,2,opt,name=usage,proto3" json:"usage,omitempty"`
+	Description          string   `protobuf:"bytes,3,opt,name=description,proto3" json:"description,omitempty"`
+	XXX_NoUnkeyedLiteral struct{} `json:"-"`
+	XXX_unrecognized     []byte   `json:"-"`
+	XXX_sizecache        int32    `json:"-"`
+}
+
+func (m *CommandInfo) Reset()         { *m = CommandInfo{} }
+func (m *CommandInfo) String() string { return proto.CompactTextString(m) }
+func (*CommandInfo) ProtoMessage()    {}
+func (*CommandInfo) Descriptor() ([]byte, []int) {
+	return fileDescriptor_f80abaa17e25ccc8, []int{10}
+}
+
+func (m *CommandInfo) XXX_Unmarshal(b []byte) error {
+	return xxx_messageInfo_CommandInfo.Unmarshal(m, b)
+}
+func (m *CommandInfo) XXX_Marshal(b []byte, deterministic bool) ([]byte, error) {
+	return xxx_messageInfo_CommandInfo.Marshal(b, m, deterministic)
+}
+func (m *CommandInfo) XXX_Merge(src proto.Message) {
+	xxx_messageInfo_CommandInfo.Merge(m, src)
+}
+func (m *CommandInfo) XXX_Size() int {
+	return xxx_messageInfo_CommandInfo.Size(m)
+}
+func (m *CommandInfo) XXX_DiscardUnknown() {
+	xxx_messageInfo_CommandInfo.DiscardUnknown(m)
+}
+
+var xxx_messageInfo_CommandInfo proto.InternalMessageInfo
+
+func (m *CommandInfo) GetName() string {
+	if m != nil {
+		return m.Name
+	}
+	return ""
+}
+
+func (m *CommandInfo) GetUsage() string {
+	if m != nil {
+		return m.Usage
+	}
+	return ""
+}
+
+func (m *CommandInfo) GetDescription() string {
+	if m != nil {
+		return m.Description
+	}
+	return ""
+}
+
+type SubscribeRequest struct {
+	XXX_NoUnkeyedLiteral struct{} `json:"-"`
+	XXX_unrecognized     []byte   `json:"-"`
+	XXX_sizecache        int32    `json:"-"`
+}
+
+func (m *SubscribeRequest) Reset()         { *m = SubscribeRequest{} }
+func (m *SubscribeRequest) String() string { return proto.CompactTextString(m) }
+func (*SubscribeRequest) ProtoMessage()    {}
+func (*SubscribeRequest) Descriptor() ([]byte, []int) {
+	return fileDescriptor_f80abaa17e25ccc8, []int{11}
+}
+
+func (m *SubscribeRequest) XXX_Unmarshal(b []byte) error {
+	return xxx_messageInfo_SubscribeRequest.Unmarshal(m, b)
+}
+func (m *SubscribeRequest) XXX_Marshal(b []byte, deterministic bool) ([]byte, error) {
+	return xxx_messageInfo_SubscribeRequest.Marshal(b, m, deterministic)
+}
+func (m *SubscribeRequest) XXX_Merge(src proto.Message) {
+	xxx_messageInfo_SubscribeRequest.Merge(m, src)
+}
+func (m *SubscribeRequest) XXX_Size() int {
+	return xxx_messageInfo_SubscribeRequest.Size(m)
+}
+func (m *SubscribeRequest) XXX_DiscardUnknown() {
+	xxx_messageInfo_SubscribeRequest.DiscardUnknown(m)
+}
+
+var xxx_messageInfo_SubscribeRequest proto.InternalMessageInfo
+
+// Event is a message pushed by the provider without being invoked.
+type Event struct {
+	Channel              string    `protobuf:"bytes,1,opt,name=channel,proto3" json:"channel,omitempty"`
+	Platform             string    `protobuf:"bytes,2,opt,name=platform,proto3" json:"platform,omitempty"`
+	Response             *Response `protobuf:"bytes,3,opt,name=response,proto3" json:"response,omitempty"`
+	XXX_NoUnkeyedLiteral struct{}  `json:"-"`
+	XXX_unrecognized     []byte    `json:"-"`
+	XXX_sizecache        int32     `json:"-"`
+}
+
+func (m *Event) Reset()         { *m = Event{} }
+func (m *Event) String() string { return proto.CompactTextString(m) }
+func (*Event) ProtoMessage()    {}
+func (*Event) Descriptor() ([]byte, []int) {
+	return fileDescriptor_f80abaa17e25ccc8, []int{12}
+}
+
+func (m *Event) XXX_Unmarshal(b []byte) error {
+	return xxx_messageInfo_Event.Unmarshal(m, b)
+}
+func (m *Event) XXX_Marshal(b []byte, deterministic bool) ([]byte, error) {
+	return xxx_messageInfo_Event.Marshal(b, m, deterministic)
+}
+func (m *Event) XXX_Merge(src proto.Message) {
+	xxx_messageInfo_Event.Merge(m, src)
+}
+func (m *Event) XXX_Size() int {
+	return xxx_messageInfo_Event.Size(m)
+}
+func (m *Event) XXX_DiscardUnknown() {
+	xxx_messageInfo_Event.DiscardUnknown(m)
+}
+
+var xxx_messageInfo_Event proto.InternalMessageInfo
+
+func (m *Event) GetChannel() string {
+	if m != nil {
+		return m.Channel
+	}
+	return ""
+}
+
+func (m *Event) GetPlatform() string {
+	if m != nil {
+		return m.Platform
+	}
+	return ""
+}
+
+func (m *Event) GetResponse() *Response {
+	if m != nil {
+		return m.Response
+	}
+	return nil
+}
+
 func init() {
+	proto.RegisterEnum("Message_Target", Message_Target_name, Message_Target_value)
 	proto.RegisterType((*CommandArguments)(nil), "CommandArguments")
+	proto.RegisterType((*Invocation)(nil), "Invocation")
 	proto.RegisterType((*CommandResult)(nil), "CommandResult")
+	proto.RegisterType((*Response)(nil), "Response")
+	proto.RegisterType((*Message)(nil), "Message")
+	proto.RegisterType((*Mention)(nil), "Mention")
+	proto.RegisterType((*Embed)(nil), "Embed")
+	proto.RegisterType((*Attachment)(nil), "Attachment")
+	proto.RegisterType((*DescribeRequest)(nil), "DescribeRequest")
+	proto.RegisterType((*Manifest)(nil), "Manifest")
+	proto.RegisterType((*CommandInfo)(nil), "CommandInfo")
+	proto.RegisterType((*SubscribeRequest)(nil), "SubscribeRequest")
+	proto.RegisterType((*Event)(nil), "Event")
 }
 
 func init() { proto.RegisterFile("pb.proto", fileDescriptor_f80abaa17e25ccc8) }
 
 var fileDescriptor_f80abaa17e25ccc8 = []byte{
-	// 150 bytes of a gzipped FileDescriptorProto
-	0x1f, 0x8b, 0x08, 0x00, 0x00, 0x00, 0x00, 0x00, 0x02, 0xff, 0xe2, 0xe2, 0x28, 0x48, 0xd2, 0x2b,
-	0x28, 0xca, 0x2f, 0xc9, 0x57, 0x52, 0xe3, 0x12, 0x70, 0xce, 0xcf, 0xcd, 0x4d, 0xcc, 0x4b, 0x71,
-	0x2c, 0x4a, 0x2f, 0xcd, 0x4d, 0xcd, 0x2b, 0x29, 0x16, 0x12, 0xe2, 0x62, 0x49, 0x2c, 0x4a, 0x2f,
-	0x96, 0x60, 0x54, 0x60, 0xd6, 0xe0, 0x0c, 0x02, 0xb3, 0x95, 0xd4, 0xb9, 0x78, 0xa1, 0xea, 0x82,
-	0x52, 0x8b, 0x4b, 0x73, 0x4a, 0x84, 0xc4, 0xb8, 0xd8, 0x8a, 0xc0, 0x2c, 0x09, 0x46, 0x05, 0x46,
-	0x0d, 0xce, 0x20, 0x28, 0xcf, 0xa8, 0x90, 0x8b, 0x1d, 0xaa, 0x50, 0x48, 0x97, 0x8b, 0xcd, 0x23,
-	0x31, 0x2f, 0x25, 0x27, 0x55, 0x48, 0x50, 0x0f, 0xdd, 0x12, 0x29, 0x3e, 0x3d, 0x14, 0xf3, 0x94,
-	0x18, 0x84, 0xcc, 0xb9, 0x78, 0x20, 0xca, 0x83, 0x4b, 0x8a, 0x52, 0x13, 0x73, 0x89, 0xd2, 0xa4,
-	0xc1, 0x68, 0xc0, 0x98, 0xc4, 0x06, 0xf6, 0x8a, 0x31, 0x20, 0x00, 0x00, 0xff, 0xff, 0xf2, 0xcd,
-	0xe3, 0x8a, 0xd6, 0x00, 0x00, 0x00,
+	// 801 bytes of a gzipped FileDescriptorProto
+	0x1f, 0x8b, 0x08, 0x00, 0x00, 0x00, 0x00, 0x00, 0x02, 0xff, 0x8c, 0x54, 0xdd, 0x6e, 0xdc, 0x44,
+	0x14, 0x8e, 0x9b, 0xac, 0xd7, 0x3e, 0x4e, 0x9b, 0xcd, 0x51, 0x45, 0xad, 0x45, 0xd0, 0x30, 0x02,
+	0xb1, 0x2d, 0xea, 0x24, 0x5a, 0x24, 0xe0, 0x36, 0xb4, 0x91, 0x58, 0x89, 0x02, 0x9a, 0x04, 0xa4,
+	0x5e, 0x45, 0xb3, 0xf6, 0x64, 0x63, 0x61, 0x8f, 0x8d, 0x67, 0x1c, 0xd1, 0x87, 0x40, 0xe2, 0xb1,
+	0x78, 0x0b, 0x1e, 0x81, 0x57, 0x40, 0xf3, 0x63, 0xaf, 0xbb, 0x04, 0xa9, 0x77, 0x73, 0xbe, 0xef,
+	0xcc, 0x9c, 0xf3, 0x9d, 0x9f, 0x81, 0xa8, 0x59, 0xd3, 0xa6, 0xad, 0x75, 0x3d, 0x7f, 0xba, 0xa9,
+	0xeb, 0x4d, 0x29, 0x4e, 0xad, 0xb5, 0xee, 0x6e, 0x4e, 0x75, 0x51, 0x09, 0xa5, 0x79, 0xd5, 0x78,
+	0x87, 0x27, 0xde, 0xa1, 0x6d, 0xb2, 0x53, 0xa5, 0xb9, 0xee, 0x94, 0x23, 0xc8, 0x1d, 0xcc, 0x5e,
+	0xd6, 0x55, 0xc5, 0x65, 0x7e, 0xde, 0x6e, 0xba, 0x4a, 0x48, 0xad, 0x10, 0xe1, 0x80, 0xb7, 0x1b,
+	0x95, 0x06, 0x27, 0xfb, 0x8b, 0x98, 0xd9, 0x33, 0x7e, 0x02, 0x87, 0x99, 0xf3, 0xbb, 0x96, 0xbc,
+	0x12, 0xe9, 0x83, 0x93, 0x60, 0x11, 0xb3, 0xc4, 0x63, 0x3f, 0xf0, 0x4a, 0xe0, 0x17, 0x00, 0x85,
+	0xbc, 0xab, 0x33, 0xae, 0x8b, 0x5a, 0xa6, 0xfb, 0x27, 0xc1, 0x22, 0x59, 0x26, 0x74, 0x35, 0x40,
+	0x6c, 0x44, 0x93, 0x7f, 0x02, 0x80, 0x2d, 0x85, 0x1f, 0x42, 0xac, 0x84, 0xcc, 0x45, 0x7b, 0x5d,
+	0xe4, 0x69, 0x60, 0xdf, 0x8e, 0x1c, 0xb0, 0xca, 0xf1, 0x29, 0x24, 0x9e, 0x1c, 0x85, 0x06, 0x07,
+	0xd9, 0xc8, 0x29, 0x4c, 0xb3, 0x5b, 0x2e, 0xa5, 0x28, 0x6d, 0xd8, 0x98, 0xf5, 0x26, 0xce, 0x21,
+	0x6a, 0x4a, 0xae, 0x6f, 0xea, 0xb6, 0x4a, 0x0f, 0xdc, 0xb3, 0xbd, 0x8d, 0x8f, 0x61, 0xd2, 0xd6,
+	0xa5, 0x50, 0xe9, 0xc4, 0xea, 0x74, 0x06, 0x7e, 0x04, 0x50, 0x09, 0xa5, 0xf8, 0x46, 0x98, 0x54,
+	0x42, 0x7b, 0x27, 0xf6, 0xc8, 0x2a, 0xc7, 0x6f, 0x20, 0x1e, 0x6a, 0x9b, 0x4e, 0xad, 0xc6, 0x39,
+	0x75, 0xc5, 0xa5, 0x7d, 0xf5, 0xe9, 0x55, 0xef, 0xc1, 0xb6, 0xce, 0xe4, 0xcf, 0x00, 0x1e, 0xfa,
+	0x52, 0x33, 0xa1, 0xba, 0x52, 0xe3, 0x07, 0x10, 0xb6, 0xf6, 0xe4, 0x15, 0x7b, 0x0b, 0x9f, 0x43,
+	0xe8, 0x7a, 0x64, 0xa5, 0x26, 0x4b, 0xec, 0x03, 0xb4, 0x4d, 0x46, 0x2f, 0x2d, 0xc3, 0xbc, 0x07,
+	0x7e, 0x06, 0x51, 0x2b, 0x54, 0x53, 0x4b, 0x25, 0x7c, 0xc9, 0x63, 0xca, 0x3c, 0xc0, 0x06, 0xca,
+	0x68, 0xcd, 0x6e, 0x3b, 0xf9, 0xab, 0x2d, 0x42, 0xc4, 0x9c, 0x41, 0xce, 0x20, 0xea, 0x7d, 0xf1,
+	0x53, 0x88, 0xbc, 0x4a, 0xd7, 0xf8, 0x64, 0x19, 0xd1, 0xd7, 0x0e, 0x60, 0x03, 0x43, 0xfe, 0x0e,
+	0x60, 0xea, 0x51, 0x33, 0x26, 0x5a, 0xfc, 0xde, 0x27, 0x6f, 0xcf, 0xf8, 0x39, 0x84, 0x9a, 0xb7,
+	0x1b, 0xa1, 0x6d, 0xea, 0x8f, 0x96, 0x47, 0xfd, 0x1b, 0xf4, 0xca, 0xc2, 0xcc, 0xd3, 0x2e, 0x9c,
+	0x34, 0xbd, 0x57, 0xe9, 0xfe, 0x10, 0xce, 0x02, 0x6c, 0x60, 0xf0, 0x63, 0x08, 0x45, 0xb5, 0x16,
+	0xb9, 0x4a, 0x0f, 0xac, 0x4f, 0x48, 0x2f, 0x8c, 0xc9, 0x3c, 0x8a, 0x2f, 0x20, 0xe1, 0x5a, 0xf3,
+	0xec, 0xd6, 0xdc, 0x70, 0x8d, 0x34, 0x33, 0x77, 0x3e, 0x60, 0x6c, 0xcc, 0x13, 0x02, 0xa1, 0x4b,
+	0x03, 0x63, 0x98, 0xb0, 0x8b, 0x9f, 0xbe, 0x7f, 0x33, 0xdb, 0xc3, 0x87, 0x10, 0x7f, 0xcb, 0x7e,
+	0x3c, 0x7f, 0xf5, 0xf2, 0xfc, 0xf2, 0x6a, 0x16, 0x90, 0xaf, 0x8c, 0x40, 0x1b, 0x1e, 0x9f, 0xc0,
+	0xb4, 0x53, 0xe3, 0x91, 0x0c, 0x8d, 0xb9, 0xca, 0x8d, 0xf2, 0xd1, 0x24, 0xda, 0x33, 0x91, 0x30,
+	0xb1, 0xb9, 0x99, 0x52, 0xeb, 0x42, 0x97, 0xc2, 0xdf, 0x71, 0x06, 0x9e, 0x40, 0x92, 0x0b, 0x95,
+	0xb5, 0x45, 0x63, 0xb7, 0xc3, 0xaf, 0xcf, 0x08, 0xc2, 0x19, 0xec, 0x77, 0x6d, 0x3f, 0xc0, 0xe6,
+	0x68, 0x96, 0xa2, 0xa8, 0xcc, 0x20, 0x1a, 0xdc, 0x4f, 0xaf, 0x05, 0x7e, 0x6e, 0x4b, 0x52, 0x00,
+	0x6c, 0x65, 0x0e, 0x19, 0x05, 0xdb, 0x8c, 0xdc, 0xca, 0x4a, 0x2d, 0xa4, 0xbe, 0xd6, 0x6f, 0x9b,
+	0xd1, 0xca, 0x5a, 0xec, 0xea, 0x6d, 0x23, 0xee, 0x89, 0x89, 0x70, 0x90, 0x73, 0xcd, 0x6d, 0xb8,
+	0x43, 0x66, 0xcf, 0xe4, 0x18, 0x8e, 0x5e, 0xd9, 0x44, 0xd7, 0x82, 0x89, 0xdf, 0x3a, 0xa1, 0x34,
+	0xf9, 0x23, 0x80, 0xe8, 0x35, 0x97, 0xc5, 0x8d, 0x50, 0xf7, 0x07, 0x4f, 0x61, 0x7a, 0x27, 0x5a,
+	0xb5, 0xd5, 0xda, 0x9b, 0xf8, 0x0c, 0x66, 0x76, 0x51, 0xb2, 0xba, 0xbc, 0xee, 0x5d, 0x4c, 0x02,
+	0x13, 0x76, 0xd4, 0xe3, 0xbf, 0x78, 0xd7, 0x05, 0x44, 0xfe, 0x83, 0xe9, 0x07, 0xe0, 0x90, 0xfa,
+	0x15, 0x5a, 0xc9, 0x9b, 0x9a, 0x0d, 0x2c, 0x79, 0x03, 0xc9, 0x88, 0xb8, 0x37, 0xa3, 0xc7, 0x30,
+	0xe9, 0xcc, 0x24, 0xfa, 0x7c, 0x9c, 0xb1, 0xdb, 0x97, 0xfd, 0xff, 0xf4, 0x85, 0x20, 0xcc, 0x2e,
+	0xbb, 0xf5, 0xbb, 0xf2, 0x73, 0x98, 0x5c, 0xdc, 0x99, 0xba, 0x8f, 0x7e, 0x9e, 0xe0, 0xff, 0x7f,
+	0x9e, 0x07, 0x3b, 0x3f, 0xcf, 0xfb, 0x2d, 0xed, 0xf2, 0xaf, 0x00, 0xa6, 0x5e, 0x15, 0xbe, 0x80,
+	0xf0, 0x3b, 0x2e, 0xf3, 0x52, 0xe0, 0x31, 0xdd, 0xfd, 0xb0, 0xe7, 0x8f, 0xe8, 0x3b, 0x1f, 0x0b,
+	0xd9, 0xc3, 0xaf, 0xe1, 0xd0, 0xb9, 0x5f, 0xea, 0x56, 0xf0, 0xea, 0xbd, 0x2e, 0x2d, 0x82, 0xb3,
+	0x00, 0x9f, 0x41, 0xd4, 0xf7, 0x1a, 0x67, 0x74, 0xa7, 0xed, 0xf3, 0x98, 0xf6, 0x4d, 0x27, 0x7b,
+	0xf8, 0x1c, 0xe2, 0xa1, 0x30, 0x78, 0x4c, 0x77, 0x8b, 0x34, 0x0f, 0xa9, 0xad, 0x11, 0xd9, 0x3b,
+	0x0b, 0xd6, 0xa1, 0x6d, 0xed, 0x97, 0xff, 0x0e, 0x00, 0xd0, 0xa7, 0x83, 0xdc, 0xb3, 0x06, 0x00,
+	0x00,
 }
 
 // Reference imports to suppress errors if they are not otherwise used.
@@ -137,6 +874,9 @@ const _ = grpc.SupportPackageIsVersion6
 type CommandClient interface {
 	Handle(ctx context.Context, in *CommandArguments, opts ...grpc.CallOption) (*CommandResult, error)
 	HandleStream(ctx context.Context, opts ...grpc.CallOption) (Command_HandleStreamClient, error)
+	Describe(ctx context.Context, in *DescribeRequest, opts ...grpc.CallOption) (*Manifest, error)
+	// Subscribe streams the events pushed by the provider until the hub cancels it.
+	Subscribe(ctx context.Context, in *SubscribeRequest, opts ...grpc.CallOption) (Command_SubscribeClient, error)
 }
 
 type commandClient struct {
@@ -187,10 +927,54 @@ func (x *commandHandleStreamClient) Recv() (*CommandResult, error) {
 	return m, nil
 }
 
+func (c *commandClient) Describe(ctx context.Context, in *DescribeRequest, opts ...grpc.CallOption) (*Manifest, error) {
+	out := new(Manifest)
+	err := c.cc.Invoke(ctx, "/Command/Describe", in, out, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *commandClient) Subscribe(ctx context.Context, in *SubscribeRequest, opts ...grpc.CallOption) (Command_SubscribeClient, error) {
+	stream, err := c.cc.NewStream(ctx, &_Command_serviceDesc.Streams[1], "/Command/Subscribe", opts...)
+	if err != nil {
+		return nil, err
+	}
+	x := &commandSubscribeClient{stream}
+	if err := x.ClientStream.SendMsg(in); err != nil {
+		return nil, err
+	}
+	if err := x.ClientStream.CloseSend(); err != nil {
+		return nil, err
+	}
+	return x, nil
+}
+
+type Command_SubscribeClient interface {
+	Recv() (*Event, error)
+	grpc.ClientStream
+}
+
+type commandSubscribeClient struct {
+	grpc.ClientStream
+}
+
+func (x *commandSubscribeClient) Recv() (*Event, error) {
+	m := new(Event)
+	if err := x.ClientStream.RecvMsg(m); err != nil {
+		return nil, err
+	}
+	return m, nil
+}
+
 // CommandServer is the server API for Command service.
 type CommandServer interface {
 	Handle(context.Context, *CommandArguments) (*CommandResult, error)
 	HandleStream(Command_HandleStreamServer) error
+	Describe(context.Context, *DescribeRequest) (*Manifest, error)
+	// Subscribe streams the events pushed by the provider until the hub cancels it.
+	Subscribe(*SubscribeRequest, Command_SubscribeServer) error
 }
 
 // UnimplementedCommandServer can be embedded to have forward compatible implementations.
@@ -198,10 +982,16 @@ type UnimplementedCommandServer struct {
 }
 
 func (*UnimplementedCommandServer) Handle(ctx context.Context, req *CommandArguments) (*CommandResult, error) {
-	return nil, status.Errorf(codes.Unimplemented, "method Handle not implemented")
+	return nil, status1.Errorf(codes.Unimplemented, "method Handle not implemented")
 }
 func (*UnimplementedCommandServer) HandleStream(srv Command_HandleStreamServer) error {
-	return status.Errorf(codes.Unimplemented, "method HandleStream not implemented")
+	return status1.Errorf(codes.Unimplemented, "method HandleStream not implemented")
+}
+func (*UnimplementedCommandServer) Describe(ctx context.Context, req *DescribeRequest) (*Manifest, error) {
+	return nil, status1.Errorf(codes.Unimplemented, "method Describe not implemented")
+}
+func (*UnimplementedCommandServer) Subscribe(req *SubscribeRequest, srv Command_SubscribeServer) error {
+	return status1.Errorf(codes.Unimplemented, "method Subscribe not implemented")
 }
 
 func RegisterCommandServer(s *grpc.Server, srv CommandServer) {
@@ -252,6 +1042,45 @@ func (x *commandHandleStreamServer) Recv() (*CommandArguments, error) {
 	return m, nil
 }
 
+func _Command_Describe_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(DescribeRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(CommandServer).Describe(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: "/Command/Describe",
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(CommandServer).Describe(ctx, req.(*DescribeRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _Command_Subscribe_Handler(srv interface{}, stream grpc.ServerStream) error {
+	m := new(SubscribeRequest)
+	if err := stream.RecvMsg(m); err != nil {
+		return err
+	}
+	return srv.(CommandServer).Subscribe(m, &commandSubscribeServer{stream})
+}
+
+type Command_SubscribeServer interface {
+	Send(*Event) error
+	grpc.ServerStream
+}
+
+type commandSubscribeServer struct {
+	grpc.ServerStream
+}
+
+func (x *commandSubscribeServer) Send(m *Event) error {
+	return x.ServerStream.SendMsg(m)
+}
+
 var _Command_serviceDesc = grpc.ServiceDesc{
 	ServiceName: "Command",
 	HandlerType: (*CommandServer)(nil),
@@ -260,6 +1089,10 @@ var _Command_serviceDesc = grpc.ServiceDesc{
 			MethodName: "Handle",
 			Handler:    _Command_Handle_Handler,
 		},
+		{
+			MethodName: "Describe",
+			Handler:    _Command_Describe_Handler,
+		},
 	},
 	Streams: []grpc.StreamDesc{
 		{
@@ -268,6 +1101,11 @@ var _Command_serviceDesc = grpc.ServiceDesc{
 			ServerStreams: true,
 			ClientStreams: true,
 		},
+		{
+			StreamName:    "Subscribe",
+			Handler:       _Command_Subscribe_Handler,
+			ServerStreams: true,
+		},
 	},
 	Metadata: "pb.proto",
 }
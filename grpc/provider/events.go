@@ -0,0 +1,101 @@
+package provider
+
+import (
+	"context"
+	"errors"
+
+	"github.com/subcommands_test/cli/lib"
+	"github.com/subcommands_test/grpc/pb"
+)
+
+// ErrNoSubscribers is returned by Push if no hub subscribed to the events.
+var ErrNoSubscribers = errors.New("no subscribers")
+
+// subscriber is a running Subscribe rpc.
+type subscriber struct {
+	events chan *pb.Event
+	done   <-chan struct{}
+}
+
+// Subscribe sends all pushed events until the hub cancels the rpc or the
+// server is drained.
+func (prov *CommandProviderServer) Subscribe(req *pb.SubscribeRequest, stream pb.Command_SubscribeServer) error {
+	sub := &subscriber{
+		events: make(chan *pb.Event),
+		done:   stream.Context().Done(),
+	}
+	prov.mu.Lock()
+	if prov.subscribers == nil {
+		prov.subscribers = make(map[*subscriber]struct{})
+	}
+	prov.subscribers[sub] = struct{}{}
+	prov.mu.Unlock()
+	defer func() {
+		prov.mu.Lock()
+		defer prov.mu.Unlock()
+		delete(prov.subscribers, sub)
+	}()
+
+	for {
+		select {
+		case event := <-sub.events:
+			if err := stream.Send(event); err != nil {
+				return err
+			}
+		case <-sub.done:
+			return nil
+		case <-prov.drainer.Drained():
+			return nil
+		}
+	}
+}
+
+// Push sends the event to all subscribed hubs. It blocks until every
+// subscriber got the event or ctx is done.
+func (prov *CommandProviderServer) Push(ctx context.Context, event *lib.Event) error {
+	prov.mu.Lock()
+	subscribers := make([]*subscriber, 0, len(prov.subscribers))
+	for sub := range prov.subscribers {
+		subscribers = append(subscribers, sub)
+	}
+	prov.mu.Unlock()
+	if len(subscribers) == 0 {
+		return ErrNoSubscribers
+	}
+
+	converted := EventToProto(event)
+	for _, sub := range subscribers {
+		select {
+		case sub.events <- converted:
+		case <-sub.done:
+			// Gone in the meantime, the others still get the event
+		case <-ctx.Done():
+			return ctx.Err()
+		}
+	}
+	return nil
+}
+
+// EventToProto converts the event into its protobuf representation.
+func EventToProto(event *lib.Event) *pb.Event {
+	converted := &pb.Event{
+		Channel:  event.Channel,
+		Platform: event.Platform,
+	}
+	if event.Response != nil {
+		converted.Response = ResponseToProto(event.Response)
+	}
+	return converted
+}
+
+// EventFromProto converts the protobuf representation of an event back.
+func EventFromProto(event *pb.Event) *lib.Event {
+	converted := &lib.Event{
+		Channel:  event.Channel,
+		Platform: event.Platform,
+	}
+	if event.Response != nil {
+		converted.Response = ResponseFromProto(event.Response)
+	}
+	return converted
+}
@@ -0,0 +1,40 @@
+package provider
+
+import (
+	"github.com/golang/protobuf/ptypes"
+	"github.com/subcommands_test/cli/lib"
+	"github.com/subcommands_test/grpc/pb"
+)
+
+// InvocationToProto converts the invocation into its protobuf representation.
+func InvocationToProto(inv *lib.Invocation) *pb.Invocation {
+	converted := &pb.Invocation{
+		SenderId:   inv.SenderID,
+		SenderName: inv.SenderName,
+		Channel:    inv.Channel,
+		Platform:   inv.Platform,
+		Roles:      inv.Roles,
+		MessageId:  inv.MessageID,
+	}
+	if !inv.Timestamp.IsZero() {
+		// Only fails for timestamps out of the range of protobuf, which are dropped
+		converted.Timestamp, _ = ptypes.TimestampProto(inv.Timestamp)
+	}
+	return converted
+}
+
+// InvocationFromProto converts the protobuf representation of an invocation back.
+func InvocationFromProto(inv *pb.Invocation) *lib.Invocation {
+	converted := &lib.Invocation{
+		SenderID:   inv.SenderId,
+		SenderName: inv.SenderName,
+		Channel:    inv.Channel,
+		Platform:   inv.Platform,
+		Roles:      inv.Roles,
+		MessageID:  inv.MessageId,
+	}
+	if inv.Timestamp != nil {
+		converted.Timestamp, _ = ptypes.Timestamp(inv.Timestamp)
+	}
+	return converted
+}
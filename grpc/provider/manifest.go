@@ -0,0 +1,42 @@
+package provider
+
+import (
+	"github.com/subcommands_test/cli/lib"
+	"github.com/subcommands_test/grpc/pb"
+)
+
+// ManifestToProto converts the manifest into its protobuf representation.
+func ManifestToProto(manifest *lib.Manifest) *pb.Manifest {
+	commands := make([]*pb.CommandInfo, len(manifest.Commands))
+	for i, info := range manifest.Commands {
+		commands[i] = &pb.CommandInfo{
+			Name:        info.Name,
+			Usage:       info.Usage,
+			Description: info.Description,
+		}
+	}
+	return &pb.Manifest{
+		Name:            manifest.Name,
+		Version:         manifest.Version,
+		ProtocolVersion: int32(manifest.ProtocolVersion),
+		Commands:        commands,
+	}
+}
+
+// ManifestFromProto converts the protobuf representation of a manifest back.
+func ManifestFromProto(manifest *pb.Manifest) *lib.Manifest {
+	commands := make([]lib.CommandInfo, len(manifest.Commands))
+	for i, info := range manifest.Commands {
+		commands[i] = lib.CommandInfo{
+			Name:        info.Name,
+			Usage:       info.Usage,
+			Description: info.Description,
+		}
+	}
+	return &lib.Manifest{
+		Name:            manifest.Name,
+		Version:         manifest.Version,
+		ProtocolVersion: int(manifest.ProtocolVersion),
+		Commands:        commands,
+	}
+}
@@ -2,29 +2,100 @@ package provider
 
 import (
 	"context"
-	"fmt"
 	"io"
+	"sync"
 
+	"github.com/subcommands_test/cli/lib"
 	"github.com/subcommands_test/grpc/pb"
+	"google.golang.org/grpc/status"
 )
 
+// ServiceName is the name of the Command service used by the health service.
+const ServiceName = "Command"
+
+// CommandProviderServer is a pb.CommandServer handling invocations with
+// its handler, like lib.ReaderWriterProvider does for the cli transport.
+// The handler is chosen like lib.Handlers does, lib.HelloProvider is used
+// without any. A *lib.Registry routing the invocations by command name is
+// set as RichHandler. Manifest is returned by Describe.
+//
+// Events pushed with Push are sent to all hubs subscribed with the
+// Subscribe rpc.
+//
+// After Drain invocations are refused, so the ones in flight can finish
+// while the grpc server is stopped gracefully. Serve takes care of that.
 type CommandProviderServer struct {
+	Manifest *lib.Manifest
+
+	Handler         lib.Command
+	HandlerFunc     lib.CommandFunc
+	FallibleHandler lib.FallibleCommand
+	ContextHandler  lib.ContextCommand
+	RichHandler     lib.RichCommand
+
+	// drainer ends all subscriptions once drained
+	drainer     lib.Drainer
+	mu          sync.Mutex
+	subscribers map[*subscriber]struct{}
+}
+
+// Drain refuses all following invocations and ends all subscriptions.
+func (prov *CommandProviderServer) Drain() {
+	prov.drainer.Drain()
 }
 
-func (prov *CommandProviderServer) handle(args []string) string {
-	var message string
-	if len(args) == 0 {
-		message = "Hello!"
+// InFlight returns the number of invocations currently handled.
+func (prov *CommandProviderServer) InFlight() int {
+	return prov.drainer.InFlight()
+}
+
+// command returns the command handling all invocations.
+func (prov *CommandProviderServer) command() lib.RichCommand {
+	return lib.Handlers{
+		Handler:         prov.Handler,
+		HandlerFunc:     prov.HandlerFunc,
+		FallibleHandler: prov.FallibleHandler,
+		ContextHandler:  prov.ContextHandler,
+		RichHandler:     prov.RichHandler,
+	}.Command(lib.Rich(lib.WithContext(lib.CommandFunc(lib.HelloProvider))))
+}
+
+// handle invokes the command. Chunks are passed to chunks if set and
+// collected into the response otherwise.
+func (prov *CommandProviderServer) handle(ctx context.Context, arg *pb.CommandArguments, chunks func(*lib.Response) error) (*lib.Response, error) {
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+	if err := prov.drainer.Begin(); err != nil {
+		return nil, err
+	}
+	defer prov.drainer.End()
+	if arg.Invocation != nil {
+		ctx = lib.WithInvocation(ctx, InvocationFromProto(arg.Invocation))
+	}
+	args := arg.Args
+	if arg.CommandName != "" {
+		args = append([]string{arg.CommandName}, args...)
+	}
+	cmd := prov.command()
+	if chunks != nil {
+		ctx = lib.WithChunks(ctx, chunks)
 	} else {
-		message = fmt.Sprintf("Hello, %s!", args[0])
+		cmd = lib.Collect(cmd)
 	}
-	return message
+	resp, err := cmd.HandleRich(ctx, args)
+	if err == nil && resp == nil {
+		resp = lib.NoReply()
+	}
+	return resp, err
 }
 
 func (prov *CommandProviderServer) Handle(ctx context.Context, arg *pb.CommandArguments) (*pb.CommandResult, error) {
-	return &pb.CommandResult{
-		Result: prov.handle(arg.Args),
-	}, nil
+	resp, err := prov.handle(ctx, arg, nil)
+	if err != nil {
+		return nil, StatusFromError(err)
+	}
+	return ResultToProto(resp), nil
 }
 
 func (prov *CommandProviderServer) HandleStream(stream pb.Command_HandleStreamServer) error {
@@ -36,11 +107,32 @@ func (prov *CommandProviderServer) HandleStream(stream pb.Command_HandleStreamSe
 		if err != nil {
 			return err
 		}
-		err = stream.Send(&pb.CommandResult{
-			Result: prov.handle(in.Args),
+		// Chunks are sent as soon as they're ready, the result marks the end
+		resp, err := prov.handle(stream.Context(), in, func(chunk *lib.Response) error {
+			out := ResultToProto(chunk)
+			out.Chunk = true
+			return stream.Send(out)
 		})
+		var out *pb.CommandResult
+		if err != nil {
+			// Failures are sent in place of the result to keep the stream alive
+			out = &pb.CommandResult{
+				Status: status.Convert(StatusFromError(err)).Proto(),
+			}
+		} else {
+			out = ResultToProto(resp)
+		}
+		err = stream.Send(out)
 		if err != nil {
 			return err
 		}
 	}
 }
+
+func (prov *CommandProviderServer) Describe(ctx context.Context, req *pb.DescribeRequest) (*pb.Manifest, error) {
+	manifest := prov.Manifest
+	if manifest == nil {
+		manifest = lib.NewManifest("", "")
+	}
+	return ManifestToProto(manifest), nil
+}
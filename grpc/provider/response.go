@@ -0,0 +1,114 @@
+package provider
+
+import (
+	"github.com/subcommands_test/cli/lib"
+	"github.com/subcommands_test/grpc/pb"
+)
+
+var targetToProto = map[lib.Target]pb.Message_Target{
+	"":                  pb.Message_REPLY,
+	lib.TargetReply:     pb.Message_REPLY,
+	lib.TargetBroadcast: pb.Message_BROADCAST,
+}
+
+var targetFromProto = map[pb.Message_Target]lib.Target{
+	pb.Message_REPLY:     lib.TargetReply,
+	pb.Message_BROADCAST: lib.TargetBroadcast,
+}
+
+// ResponseToProto converts the response into its protobuf representation.
+func ResponseToProto(resp *lib.Response) *pb.Response {
+	messages := make([]*pb.Message, len(resp.Messages))
+	for i, msg := range resp.Messages {
+		mentions := make([]*pb.Mention, len(msg.Mentions))
+		for j, mention := range msg.Mentions {
+			mentions[j] = &pb.Mention{
+				UserId: mention.UserID,
+				Name:   mention.Name,
+			}
+		}
+		embeds := make([]*pb.Embed, len(msg.Embeds))
+		for j, embed := range msg.Embeds {
+			embeds[j] = &pb.Embed{
+				Title:       embed.Title,
+				Description: embed.Description,
+				Url:         embed.URL,
+				ImageUrl:    embed.ImageURL,
+			}
+		}
+		attachments := make([]*pb.Attachment, len(msg.Attachments))
+		for j, attachment := range msg.Attachments {
+			attachments[j] = &pb.Attachment{
+				Name:        attachment.Name,
+				ContentType: attachment.ContentType,
+				Url:         attachment.URL,
+				Data:        attachment.Data,
+			}
+		}
+		messages[i] = &pb.Message{
+			Text:        msg.Text,
+			Target:      targetToProto[msg.Target],
+			Mentions:    mentions,
+			Embeds:      embeds,
+			Attachments: attachments,
+		}
+	}
+	return &pb.Response{
+		Messages: messages,
+	}
+}
+
+// ResponseFromProto converts the protobuf representation of a response back.
+func ResponseFromProto(resp *pb.Response) *lib.Response {
+	messages := make([]*lib.Message, len(resp.Messages))
+	for i, msg := range resp.Messages {
+		converted := &lib.Message{
+			Text:   msg.Text,
+			Target: targetFromProto[msg.Target],
+		}
+		for _, mention := range msg.Mentions {
+			converted.Mention(mention.UserId, mention.Name)
+		}
+		for _, embed := range msg.Embeds {
+			converted.Embed(lib.Embed{
+				Title:       embed.Title,
+				Description: embed.Description,
+				URL:         embed.Url,
+				ImageURL:    embed.ImageUrl,
+			})
+		}
+		for _, attachment := range msg.Attachments {
+			converted.Attach(lib.Attachment{
+				Name:        attachment.Name,
+				ContentType: attachment.ContentType,
+				URL:         attachment.Url,
+				Data:        attachment.Data,
+			})
+		}
+		messages[i] = converted
+	}
+	return &lib.Response{
+		Messages: messages,
+	}
+}
+
+// ResultToProto converts the response into a CommandResult. Single text
+// replies are only sent as result.
+func ResultToProto(resp *lib.Response) *pb.CommandResult {
+	result := &pb.CommandResult{
+		Result: resp.String(),
+	}
+	if !resp.IsText() {
+		result.Response = ResponseToProto(resp)
+	}
+	return result
+}
+
+// ResultFromProto returns the structured response of the CommandResult.
+// Results without response are mapped to a single text message.
+func ResultFromProto(result *pb.CommandResult) *lib.Response {
+	if result.Response == nil {
+		return lib.Text(result.Result)
+	}
+	return ResponseFromProto(result.Response)
+}
@@ -0,0 +1,143 @@
+package provider
+
+import (
+	"context"
+	"errors"
+	"log"
+	"net"
+	"os"
+	"os/signal"
+	"syscall"
+	"time"
+
+	"github.com/subcommands_test/cli/lib"
+	"github.com/subcommands_test/grpc/pb"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/health"
+	healthpb "google.golang.org/grpc/health/grpc_health_v1"
+)
+
+// Options configure how Serve listens and shuts down.
+type Options struct {
+	// Network is either 'unix' or 'tcp'. Defaults to 'unix'.
+	Network string
+	// Address to listen on. Defaults to lib.SocketPath of Name for 'unix'
+	// and ':8080' for 'tcp'.
+	Address string
+	// Name of the provider, which derives the default socket path so
+	// multiple providers don't share one. Defaults to the name of the
+	// server's manifest or 'grpc_subcommand' without manifest.
+	Name string
+	// SocketMode, SocketOwner and SocketGroup set the permissions of the
+	// unix socket, see lib.SetSocketPermissions. Zero values keep the
+	// defaults of the process.
+	SocketMode  os.FileMode
+	SocketOwner string
+	SocketGroup string
+	// GracePeriod is the time in-flight invocations have to finish on
+	// shutdown. Defaults to 5s.
+	GracePeriod time.Duration
+	// Signals stopping the server. Defaults to SIGINT and SIGTERM.
+	Signals []os.Signal
+	// ServerOptions are passed to grpc.NewServer.
+	ServerOptions []grpc.ServerOption
+}
+
+func (opts *Options) defaults(manifest *lib.Manifest) {
+	if opts.Network == "" {
+		opts.Network = "unix"
+	}
+	if opts.Name == "" && manifest != nil {
+		opts.Name = manifest.Name
+	}
+	if opts.Name == "" {
+		opts.Name = "grpc_subcommand"
+	}
+	if opts.Address == "" && opts.Network == "unix" {
+		opts.Address = lib.SocketPath(opts.Name)
+	} else if opts.Address == "" {
+		opts.Address = ":8080"
+	}
+	if opts.GracePeriod <= 0 {
+		opts.GracePeriod = 5 * time.Second
+	}
+	if len(opts.Signals) == 0 {
+		opts.Signals = []os.Signal{os.Interrupt, syscall.SIGTERM}
+	}
+}
+
+// Serve listens on the address of opts and serves the Command and health
+// services with the server until a signal is received or ctx is done. It
+// announces that it's ready with lib.AnnounceReady once it's listening.
+// Unix sockets are listened on with lib.ListenUnix, so stale ones left by a
+// crashed provider are replaced, their permissions hold from the start and
+// they're removed on shutdown.
+//
+// On shutdown the health service reports NOT_SERVING and the server is
+// drained: in-flight invocations get the grace period to finish before
+// they're abandoned.
+func Serve(ctx context.Context, server *CommandProviderServer, opts Options) error {
+	opts.defaults(server.Manifest)
+	var lis net.Listener
+	var err error
+	if opts.Network == "unix" {
+		lis, err = lib.ListenUnix(opts.Address, opts.SocketMode, opts.SocketOwner, opts.SocketGroup)
+	} else {
+		lis, err = net.Listen(opts.Network, opts.Address)
+	}
+	if err != nil {
+		return err
+	}
+	defer lis.Close()
+	grpcServer := grpc.NewServer(opts.ServerOptions...)
+	pb.RegisterCommandServer(grpcServer, server)
+	healthServer := health.NewServer()
+	healthServer.SetServingStatus(ServiceName, healthpb.HealthCheckResponse_SERVING)
+	healthpb.RegisterHealthServer(grpcServer, healthServer)
+
+	served := make(chan error, 1)
+	go func() {
+		served <- grpcServer.Serve(lis)
+	}()
+	lib.AnnounceReady(os.Stderr)
+
+	sigs := make(chan os.Signal, 1)
+	signal.Notify(sigs, opts.Signals...)
+	defer signal.Stop(sigs)
+
+	select {
+	case err := <-served:
+		// Server has been closed for any reason
+		return err
+	case sig := <-sigs:
+		log.Printf("Signal received: %v\n", sig)
+	case <-ctx.Done():
+	}
+
+	// Health checks report NOT_SERVING from now on, so the hub stops
+	// routing to us.
+	healthServer.Shutdown()
+	server.Drain()
+	stopped := make(chan struct{})
+	go func() {
+		defer close(stopped)
+		grpcServer.GracefulStop()
+	}()
+	select {
+	case <-stopped:
+	case <-time.After(opts.GracePeriod):
+		// Open streams or slow invocations keep the server from stopping
+		abandoned := server.InFlight()
+		grpcServer.Stop()
+		log.Printf("Grace period exceeded, abandoned %d invocations\n", abandoned)
+	}
+	select {
+	case err := <-served:
+		if err == grpc.ErrServerStopped {
+			return nil
+		}
+		return err
+	case <-time.After(time.Second):
+		return errors.New("server wasn't closed after stop")
+	}
+}
@@ -0,0 +1,93 @@
+package provider
+
+import (
+	"context"
+	"io/ioutil"
+	"net"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/subcommands_test/cli/lib"
+	"github.com/subcommands_test/grpc/pb"
+	"google.golang.org/grpc"
+)
+
+func TestServe(t *testing.T) {
+	dir, err := ioutil.TempDir("", "grpcprov")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	address := filepath.Join(dir, "serve.sock")
+	// A crashed provider left its socket behind
+	stale, err := net.Listen("unix", address)
+	if err != nil {
+		t.Fatal(err)
+	}
+	stale.(*net.UnixListener).SetUnlinkOnClose(false)
+	stale.Close()
+
+	server := &CommandProviderServer{
+		FallibleHandler: lib.FallibleCommandFunc(func(args []string) (string, error) {
+			if len(args) == 0 {
+				return "", lib.Errorf(lib.CodeInvalidArgument, "name missing")
+			}
+			return "Hi " + args[0], nil
+		}),
+	}
+	ctx, cancel := context.WithCancel(context.Background())
+	served := make(chan error, 1)
+	go func() {
+		served <- Serve(ctx, server, Options{Address: address, SocketMode: 0600, GracePeriod: time.Second})
+	}()
+
+	conn, err := grpc.Dial("unix://"+address, grpc.WithInsecure())
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer conn.Close()
+	client := pb.NewCommandClient(conn)
+	resp, err := client.Handle(context.Background(), &pb.CommandArguments{Args: []string{"Kevin"}}, grpc.WaitForReady(true))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if resp.Result != "Hi Kevin" {
+		t.Errorf("invalid result '%s'", resp.Result)
+	}
+	if info, err := os.Stat(address); err != nil || info.Mode().Perm() != 0600 {
+		t.Errorf("invalid socket permissions %v %v", info.Mode(), err)
+	}
+	_, err = client.Handle(context.Background(), &pb.CommandArguments{})
+	if lib.AsError(ErrorFromStatus(err)).Code != lib.CodeInvalidArgument {
+		t.Errorf("expected invalid argument error, got %v", err)
+	}
+
+	conn.Close()
+	cancel()
+	select {
+	case err := <-served:
+		if err != nil {
+			t.Error(err)
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatal("Serve didn't return after ctx was done")
+	}
+	if _, err := os.Stat(address); !os.IsNotExist(err) {
+		t.Errorf("socket wasn't removed on shutdown: %v", err)
+	}
+}
+
+func TestOptions_Address(t *testing.T) {
+	opts := Options{}
+	opts.defaults(lib.NewManifest("dice", lib.Version))
+	if opts.Address != lib.SocketPath("dice") {
+		t.Errorf("socket path isn't derived from the name: %s", opts.Address)
+	}
+	opts = Options{Network: "tcp"}
+	opts.defaults(nil)
+	if opts.Address != ":8080" {
+		t.Errorf("invalid default tcp address %s", opts.Address)
+	}
+}
@@ -0,0 +1,51 @@
+package provider
+
+import (
+	"context"
+
+	"github.com/subcommands_test/cli/lib"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+var grpcCodes = map[lib.Code]codes.Code{
+	lib.CodeInternal:         codes.Internal,
+	lib.CodeInvalidArgument:  codes.InvalidArgument,
+	lib.CodeNotFound:         codes.NotFound,
+	lib.CodePermissionDenied: codes.PermissionDenied,
+	lib.CodeUnavailable:      codes.Unavailable,
+}
+
+// StatusFromError converts an error returned by a command into a grpc status error.
+func StatusFromError(err error) error {
+	if err == nil {
+		return nil
+	}
+	if err == context.Canceled || err == context.DeadlineExceeded {
+		return status.FromContextError(err).Err()
+	}
+	cmdErr := lib.AsError(err)
+	code, ok := grpcCodes[cmdErr.Code]
+	if !ok {
+		code = codes.Internal
+	}
+	return status.Error(code, cmdErr.Message)
+}
+
+// ErrorFromStatus converts a grpc status error back into a *lib.Error.
+// Errors with codes not used for command errors are returned unchanged.
+func ErrorFromStatus(err error) error {
+	s, ok := status.FromError(err)
+	if !ok || s.Code() == codes.OK {
+		return err
+	}
+	for libCode, code := range grpcCodes {
+		if code == s.Code() {
+			return &lib.Error{
+				Code:    libCode,
+				Message: s.Message(),
+			}
+		}
+	}
+	return err
+}
@@ -0,0 +1,131 @@
+package hub
+
+import (
+	"bufio"
+	"context"
+	"fmt"
+	"io"
+	"strings"
+	"sync"
+
+	"github.com/subcommands_test/cli/lib"
+)
+
+// CliProvider communicates with a lib.ReaderWriterProvider by writing
+// the arguments as a single line and reading a single line as result.
+// Arguments are quoted by lib.JoinArgs if necessary. Arguments containing
+// line breaks can't be sent as a single line and are refused.
+//
+// As the line protocol relies on ordering, results are matched to the
+// invocations in the order they were written. The protocol can't tell the
+// provider to cancel an invocation, so the result of an invocation whose
+// ctx is done is skipped once it arrives. As the provider handles one line
+// after another, a command which never returns blocks all following
+// invocations, so timeouts need the framed protocol.
+type CliProvider struct {
+	// writeMu keeps the order of written lines and waiting invocations the same
+	writeMu sync.Mutex
+	input   io.Writer
+
+	mu      sync.Mutex
+	waiting []chan lineResult
+	err     error
+}
+
+type lineResult struct {
+	line string
+	err  error
+}
+
+// NewCliProvider creates a CliProvider writing invocations to input
+// (e.g. the stdin of the provider) and reading results from output
+// (e.g. its stdout). Reading starts immediately.
+func NewCliProvider(input io.Writer, output io.Reader) *CliProvider {
+	prov := &CliProvider{
+		input: input,
+	}
+	go prov.readLoop(bufio.NewReader(output))
+	return prov
+}
+
+// readLoop passes every line to the oldest waiting invocation until output fails.
+func (prov *CliProvider) readLoop(output *bufio.Reader) {
+	for {
+		line, err := output.ReadString('\n')
+		if err != nil {
+			prov.fail(err)
+			return
+		}
+		prov.mu.Lock()
+		if len(prov.waiting) > 0 {
+			prov.waiting[0] <- lineResult{line: strings.TrimSpace(line)}
+			prov.waiting = prov.waiting[1:]
+		}
+		prov.mu.Unlock()
+	}
+}
+
+// Invoke writes the arguments and waits for the result or until ctx is done.
+func (prov *CliProvider) Invoke(ctx context.Context, command string, args []string) (string, error) {
+	if err := ctx.Err(); err != nil {
+		return "", err
+	}
+	line := lib.JoinArgs(withCommand(command, args))
+	if strings.ContainsAny(line, "\r\n") {
+		return "", lib.Errorf(lib.CodeInvalidArgument, "arguments with line breaks can't be sent as a single line")
+	}
+	// Buffered, so results of abandoned invocations don't block the reader
+	result := make(chan lineResult, 1)
+
+	prov.writeMu.Lock()
+	prov.mu.Lock()
+	err := prov.err
+	if err == nil {
+		prov.waiting = append(prov.waiting, result)
+	}
+	prov.mu.Unlock()
+	if err == nil {
+		_, err = fmt.Fprintln(prov.input, line)
+		if err != nil {
+			prov.fail(err)
+		}
+	}
+	prov.writeMu.Unlock()
+	if err != nil {
+		return "", err
+	}
+
+	select {
+	case res := <-result:
+		return res.line, res.err
+	case <-ctx.Done():
+		return "", ctx.Err()
+	}
+}
+
+// fail fails all waiting and following invocations, as the order of lines
+// can't be relied on anymore.
+func (prov *CliProvider) fail(err error) {
+	prov.mu.Lock()
+	defer prov.mu.Unlock()
+	if prov.err == nil {
+		prov.err = err
+	}
+	for _, waiting := range prov.waiting {
+		waiting <- lineResult{err: err}
+	}
+	prov.waiting = nil
+}
+
+// Describe fails with ErrNoManifest, as the line protocol has no handshake.
+func (prov *CliProvider) Describe(ctx context.Context) (*lib.Manifest, error) {
+	return nil, ErrNoManifest
+}
+
+// Close closes the input of the provider if possible, which stops it.
+func (prov *CliProvider) Close() error {
+	if closer, ok := prov.input.(io.Closer); ok {
+		return closer.Close()
+	}
+	return nil
+}
@@ -0,0 +1,18 @@
+package hub
+
+import (
+	"errors"
+
+	"github.com/subcommands_test/cli/lib"
+)
+
+// IsCommandError reports whether err was returned by the command because it
+// refused the invocation, e.g. due to invalid arguments. All other errors mean
+// the provider failed or couldn't be reached.
+func IsCommandError(err error) bool {
+	var cmdErr *lib.Error
+	if !errors.As(err, &cmdErr) {
+		return false
+	}
+	return cmdErr.Code != lib.CodeInternal && cmdErr.Code != lib.CodeUnavailable
+}
@@ -0,0 +1,115 @@
+package hub
+
+import (
+	"context"
+	"errors"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/subcommands_test/cli/lib"
+	"github.com/subcommands_test/grpc/pb"
+	"github.com/subcommands_test/grpc/provider"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/status"
+)
+
+var errRefused = lib.Errorf(lib.CodeInvalidArgument, "name missing")
+
+func testRefused(t *testing.T, prov Provider) {
+	_, err := prov.Invoke(context.Background(), "", nil)
+	if !IsCommandError(err) {
+		t.Fatalf("expected command error, got %v", err)
+	}
+	if cmdErr := lib.AsError(err); *cmdErr != *errRefused {
+		t.Errorf("invalid error %+v, expected %+v", *cmdErr, *errRefused)
+	}
+}
+
+func TestFramedCliProvider_Error(t *testing.T) {
+	inReader, inWriter := io.Pipe()
+	outReader, outWriter := io.Pipe()
+	provider := &lib.ReaderWriterProvider{
+		Input:  inReader,
+		Output: outWriter,
+		Framed: true,
+		FallibleHandler: lib.FallibleCommandFunc(func(args []string) (string, error) {
+			if len(args) == 0 {
+				return "", errRefused
+			}
+			panic("failed")
+		}),
+	}
+	provider.Start()
+
+	prov := NewFramedCliProvider(inWriter, outReader)
+	defer prov.Close()
+	testRefused(t, prov)
+
+	_, err := prov.Invoke(context.Background(), "", []string{"Kevin"})
+	if err == nil || IsCommandError(err) || lib.AsError(err).Code != lib.CodeInternal {
+		t.Errorf("expected internal error, got %v", err)
+	}
+}
+
+func TestWebProvider_Error(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		http.Error(w, errRefused.Message, errRefused.Code.HTTPStatus())
+	}))
+	defer srv.Close()
+
+	prov := NewWebProvider(srv.URL)
+	defer prov.Close()
+	testRefused(t, prov)
+}
+
+type refusingServer struct {
+	pb.UnimplementedCommandServer
+}
+
+func (refusingServer) Handle(context.Context, *pb.CommandArguments) (*pb.CommandResult, error) {
+	return nil, provider.StatusFromError(errRefused)
+}
+
+func (refusingServer) HandleStream(stream pb.Command_HandleStreamServer) error {
+	for {
+		if _, err := stream.Recv(); err != nil {
+			return nil
+		}
+		err := stream.Send(&pb.CommandResult{
+			Status: status.Convert(provider.StatusFromError(errRefused)).Proto(),
+		})
+		if err != nil {
+			return err
+		}
+	}
+}
+
+func TestGrpcProvider_Error(t *testing.T) {
+	srv := grpc.NewServer()
+	pb.RegisterCommandServer(srv, &refusingServer{})
+	defer srv.Stop()
+
+	prov := NewGrpcProvider(dialBufconn(t, srv))
+	defer prov.Close()
+	testRefused(t, prov)
+
+	stream, err := NewGrpcStreamProvider(context.Background(), dialBufconn(t, srv))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer stream.Close()
+	testRefused(t, stream)
+	// The stream survives failed invocations
+	testRefused(t, stream)
+}
+
+func TestIsCommandError(t *testing.T) {
+	if IsCommandError(errors.New("connection refused")) {
+		t.Error("plain errors aren't command errors")
+	}
+	if IsCommandError(lib.Errorf(lib.CodeInternal, "crashed")) {
+		t.Error("internal errors aren't command errors")
+	}
+}
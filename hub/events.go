@@ -0,0 +1,198 @@
+package hub
+
+import (
+	"context"
+	"crypto/subtle"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"path"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/subcommands_test/cli/lib"
+)
+
+// maxEventBytes limits the JSON body of events posted to the webhook.
+const maxEventBytes = 1 << 20
+
+var (
+	// ErrUnauthenticated is returned for events of unknown providers or with an invalid token.
+	ErrUnauthenticated = errors.New("unauthenticated")
+	// ErrRateLimited is returned for events exceeding the limit of their provider.
+	ErrRateLimited = errors.New("rate limit exceeded")
+)
+
+// EventSource is a provider able to push events, like the framed cli and
+// the grpc providers.
+type EventSource interface {
+	// Subscribe passes all events pushed by the provider to events until
+	// ctx is done or the provider is closed.
+	Subscribe(ctx context.Context, events func(*lib.Event)) error
+}
+
+// EventLimit limits how many events a provider is allowed to push. Up to
+// Burst events are accepted at once and Rate events per second on average.
+// The zero EventLimit refuses all events, a zero Rate allows Burst events
+// in total.
+type EventLimit struct {
+	Rate  float64
+	Burst int
+}
+
+// EventHub accepts events pushed by registered providers and passes them
+// to Deliver. Every provider is authenticated by its token and limited by
+// its EventLimit, so a single provider can't flood the chat.
+//
+// Events of EventSources are received with Subscribe. Web providers push
+// them to the webhook served by ServeHTTP instead.
+type EventHub struct {
+	// Deliver is called with every accepted event and the name of the
+	// provider which pushed it.
+	Deliver func(provider string, event *lib.Event)
+
+	mu      sync.Mutex
+	sources map[string]*eventSource
+	// now is replaced by tests
+	now func() time.Time
+}
+
+type eventSource struct {
+	token  string
+	limit  EventLimit
+	tokens float64
+	last   time.Time
+}
+
+// Register allows the provider with the name to push events authenticated
+// by the token within the limit. Registering a provider again replaces its
+// token and limit. Providers without Burst are registered, but all their
+// events are refused with ErrRateLimited.
+func (hub *EventHub) Register(name, token string, limit EventLimit) {
+	hub.mu.Lock()
+	defer hub.mu.Unlock()
+	if hub.sources == nil {
+		hub.sources = make(map[string]*eventSource)
+	}
+	hub.sources[name] = &eventSource{
+		token:  token,
+		limit:  limit,
+		tokens: float64(limit.Burst),
+		last:   hub.clock(),
+	}
+}
+
+// Unregister refuses all further events of the provider with the name.
+func (hub *EventHub) Unregister(name string) {
+	hub.mu.Lock()
+	defer hub.mu.Unlock()
+	delete(hub.sources, name)
+}
+
+func (hub *EventHub) clock() time.Time {
+	if hub.now != nil {
+		return hub.now()
+	}
+	return time.Now()
+}
+
+// Push authenticates the event of the provider with the name by the token,
+// checks its limit and delivers it.
+func (hub *EventHub) Push(name, token string, event *lib.Event) error {
+	if err := hub.accept(name, token); err != nil {
+		return err
+	}
+	if hub.Deliver != nil {
+		hub.Deliver(name, event)
+	}
+	return nil
+}
+
+func (hub *EventHub) accept(name, token string) error {
+	hub.mu.Lock()
+	defer hub.mu.Unlock()
+	source, err := hub.authenticate(name, token)
+	if err != nil {
+		return err
+	}
+
+	// Token bucket refilled with the rate of the limit
+	now := hub.clock()
+	source.tokens += now.Sub(source.last).Seconds() * source.limit.Rate
+	if burst := float64(source.limit.Burst); source.tokens > burst {
+		source.tokens = burst
+	}
+	source.last = now
+	if source.tokens < 1 {
+		return ErrRateLimited
+	}
+	source.tokens--
+	return nil
+}
+
+// authenticate returns the registered provider with the name if the token
+// is valid. Must be called with mu held.
+func (hub *EventHub) authenticate(name, token string) (*eventSource, error) {
+	source, ok := hub.sources[name]
+	if !ok || subtle.ConstantTimeCompare([]byte(source.token), []byte(token)) != 1 {
+		return nil, ErrUnauthenticated
+	}
+	return source, nil
+}
+
+// Subscribe receives the events of the provider with the name from the
+// source until ctx is done. The provider has to be registered, but as the
+// hub reaches it on its own no token is needed. Events exceeding the limit
+// are dropped.
+func (hub *EventHub) Subscribe(ctx context.Context, name string, source EventSource) error {
+	return source.Subscribe(ctx, func(event *lib.Event) {
+		hub.mu.Lock()
+		registered, ok := hub.sources[name]
+		token := ""
+		if ok {
+			token = registered.token
+		}
+		hub.mu.Unlock()
+		hub.Push(name, token, event)
+	})
+}
+
+// ServeHTTP is the webhook of web providers. Events are posted as JSON to
+// a path ending with the name of the provider, e.g. '/events/webprov', with
+// the token as bearer token in the Authorization header. Refused events are
+// answered with 403 if the provider isn't authenticated and 503 if it
+// exceeded its limit. The body is only read after the token was checked
+// and limited to 1 MiB.
+func (hub *EventHub) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+	name := path.Base(r.URL.Path)
+	token := strings.TrimPrefix(r.Header.Get("Authorization"), "Bearer ")
+	hub.mu.Lock()
+	_, err := hub.authenticate(name, token)
+	hub.mu.Unlock()
+	// Providers registered without token can't use the webhook
+	if token == "" || err != nil {
+		http.Error(w, ErrUnauthenticated.Error(), lib.CodePermissionDenied.HTTPStatus())
+		return
+	}
+	// Only authenticated providers get their body read
+	var event lib.Event
+	if err := json.NewDecoder(http.MaxBytesReader(w, r.Body, maxEventBytes)).Decode(&event); err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	switch err := hub.Push(name, token, &event); err {
+	case nil:
+		w.WriteHeader(http.StatusNoContent)
+	case ErrUnauthenticated:
+		http.Error(w, err.Error(), lib.CodePermissionDenied.HTTPStatus())
+	case ErrRateLimited:
+		http.Error(w, err.Error(), lib.CodeUnavailable.HTTPStatus())
+	default:
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+	}
+}
@@ -0,0 +1,252 @@
+package hub
+
+import (
+	"context"
+	"errors"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/subcommands_test/cli/lib"
+	"github.com/subcommands_test/grpc/pb"
+	"github.com/subcommands_test/grpc/provider"
+	"google.golang.org/grpc"
+)
+
+func TestEventHub(t *testing.T) {
+	now := time.Unix(1581000000, 0)
+	var delivered []string
+	hub := &EventHub{
+		Deliver: func(name string, event *lib.Event) {
+			delivered = append(delivered, name+": "+event.Response.String())
+		},
+		now: func() time.Time { return now },
+	}
+	hub.Register("timer", "secret", EventLimit{Rate: 1, Burst: 2})
+	event := &lib.Event{Response: lib.Text("tick")}
+
+	if err := hub.Push("timer", "wrong", event); err != ErrUnauthenticated {
+		t.Errorf("expected ErrUnauthenticated for invalid token, got %v", err)
+	}
+	if err := hub.Push("unknown", "secret", event); err != ErrUnauthenticated {
+		t.Errorf("expected ErrUnauthenticated for unknown provider, got %v", err)
+	}
+	for i := 0; i < 2; i++ {
+		if err := hub.Push("timer", "secret", event); err != nil {
+			t.Errorf("event %d within burst refused: %v", i, err)
+		}
+	}
+	if err := hub.Push("timer", "secret", event); err != ErrRateLimited {
+		t.Errorf("expected ErrRateLimited, got %v", err)
+	}
+	now = now.Add(time.Second)
+	if err := hub.Push("timer", "secret", event); err != nil {
+		t.Errorf("event after refill refused: %v", err)
+	}
+	if len(delivered) != 3 || delivered[0] != "timer: tick" {
+		t.Errorf("invalid delivered events %v", delivered)
+	}
+
+	hub.Unregister("timer")
+	if err := hub.Push("timer", "secret", event); err != ErrUnauthenticated {
+		t.Errorf("expected ErrUnauthenticated after unregistering, got %v", err)
+	}
+
+	// The zero limit refuses all events
+	hub.Register("quiet", "secret", EventLimit{})
+	if err := hub.Push("quiet", "secret", event); err != ErrRateLimited {
+		t.Errorf("expected ErrRateLimited without limit, got %v", err)
+	}
+}
+
+func TestEventHub_Webhook(t *testing.T) {
+	delivered := make(chan string, 1)
+	hub := &EventHub{
+		Deliver: func(name string, event *lib.Event) {
+			delivered <- name + ": " + event.Channel + " " + event.Response.String()
+		},
+	}
+	hub.Register("webprov", "secret", EventLimit{Rate: 1, Burst: 1})
+	srv := httptest.NewServer(hub)
+	defer srv.Close()
+
+	event := &lib.Event{Channel: "#general", Response: lib.Text("tick")}
+	webhook := &lib.EventWebhook{URL: srv.URL + "/events/webprov", Token: "wrong"}
+	if err := webhook.Push(context.Background(), event); lib.AsError(err).Code != lib.CodePermissionDenied {
+		t.Errorf("expected permission denied, got %v", err)
+	}
+	webhook.Token = "secret"
+	if err := webhook.Push(context.Background(), event); err != nil {
+		t.Fatal(err)
+	}
+	if result := <-delivered; result != "webprov: #general tick" {
+		t.Errorf("invalid event '%s'", result)
+	}
+	if err := webhook.Push(context.Background(), event); lib.AsError(err).Code != lib.CodeUnavailable {
+		t.Errorf("expected rate limit, got %v", err)
+	}
+}
+
+// testSubscribe subscribes to the source, lets push send an event and
+// waits until it's delivered.
+func testSubscribe(t *testing.T, source EventSource, push func(context.Context, *lib.Event) error) {
+	delivered := make(chan *lib.Event, 1)
+	hub := &EventHub{
+		Deliver: func(name string, event *lib.Event) {
+			delivered <- event
+		},
+	}
+	hub.Register("prov", "", EventLimit{Rate: 1, Burst: 1})
+
+	ctx, cancel := context.WithCancel(context.Background())
+	var wg sync.WaitGroup
+	wg.Add(1)
+	go func() {
+		defer wg.Done()
+		if err := hub.Subscribe(ctx, "prov", source); err != context.Canceled {
+			t.Errorf("%T: subscription ended with %v", source, err)
+		}
+	}()
+	defer wg.Wait()
+	defer cancel()
+
+	// The subscription may not be active right away, so push until delivered
+	ticker := time.NewTicker(10 * time.Millisecond)
+	defer ticker.Stop()
+	timeout := time.After(time.Second)
+	for {
+		pushCtx, cancelPush := context.WithTimeout(ctx, 10*time.Millisecond)
+		push(pushCtx, &lib.Event{Channel: "#general", Response: lib.Text("tick")})
+		cancelPush()
+		select {
+		case event := <-delivered:
+			if event.Channel != "#general" || event.Response.String() != "tick" {
+				t.Errorf("%T: invalid event %+v", source, event)
+			}
+			return
+		case <-ticker.C:
+		case <-timeout:
+			t.Fatalf("%T: event wasn't delivered", source)
+		}
+	}
+}
+
+func TestSubscribe(t *testing.T) {
+	inReader, inWriter := io.Pipe()
+	outReader, outWriter := io.Pipe()
+	rw := &lib.ReaderWriterProvider{
+		Input:  inReader,
+		Output: outWriter,
+		Framed: true,
+	}
+	rw.Start()
+	framed := NewFramedCliProvider(inWriter, outReader)
+	defer framed.Close()
+	testSubscribe(t, framed, rw.Push)
+
+	server := &provider.CommandProviderServer{}
+	srv := grpc.NewServer()
+	pb.RegisterCommandServer(srv, server)
+	defer srv.Stop()
+	unary := NewGrpcProvider(dialBufconn(t, srv))
+	defer unary.Close()
+	testSubscribe(t, unary, server.Push)
+}
+
+func TestFramedCliProvider_Resubscribe(t *testing.T) {
+	inReader, inWriter := io.Pipe()
+	outReader, outWriter := io.Pipe()
+	rw := &lib.ReaderWriterProvider{
+		Input:  inReader,
+		Output: outWriter,
+		Framed: true,
+	}
+	rw.Start()
+	framed := NewFramedCliProvider(inWriter, outReader)
+	defer framed.Close()
+
+	subscribe := func(ctx context.Context) (<-chan *lib.Event, <-chan error) {
+		events := make(chan *lib.Event, 16)
+		ended := make(chan error, 1)
+		go func() {
+			ended <- framed.Subscribe(ctx, func(event *lib.Event) {
+				events <- event
+			})
+		}()
+		return events, ended
+	}
+	// waitEvent pushes events until one is received from events
+	waitEvent := func(events <-chan *lib.Event) {
+		t.Helper()
+		timeout := time.After(time.Second)
+		for {
+			rw.Push(context.Background(), &lib.Event{Response: lib.Text("tick")})
+			select {
+			case <-events:
+				return
+			case <-time.After(10 * time.Millisecond):
+			case <-timeout:
+				t.Fatal("event wasn't delivered")
+			}
+		}
+	}
+
+	oldCtx, cancelOld := context.WithCancel(context.Background())
+	oldEvents, oldEnded := subscribe(oldCtx)
+	waitEvent(oldEvents)
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	events, _ := subscribe(ctx)
+	waitEvent(events)
+
+	// Ending the replaced subscription must keep the newer one
+	cancelOld()
+	<-oldEnded
+	for len(events) > 0 {
+		<-events
+	}
+	waitEvent(events)
+}
+
+func TestEventHub_WebhookBody(t *testing.T) {
+	hub := &EventHub{}
+	hub.Register("webprov", "secret", EventLimit{Rate: 1, Burst: 1})
+	post := func(token string, body io.Reader) int {
+		req := httptest.NewRequest(http.MethodPost, "/events/webprov", body)
+		req.Header.Set("Authorization", "Bearer "+token)
+		rec := httptest.NewRecorder()
+		hub.ServeHTTP(rec, req)
+		return rec.Code
+	}
+
+	// The body of unauthenticated requests isn't read at all
+	if status := post("wrong", errReader{errors.New("body read")}); status != http.StatusForbidden {
+		t.Errorf("expected 403 before reading the body, got %d", status)
+	}
+	// Endless bodies are cut off
+	huge := io.MultiReader(strings.NewReader(`{"channel":"`), neverEnding('x'))
+	if status := post("secret", huge); status != http.StatusBadRequest {
+		t.Errorf("expected 400 for oversized body, got %d", status)
+	}
+}
+
+// errReader fails every read with its error.
+type errReader struct{ err error }
+
+func (r errReader) Read(p []byte) (int, error) {
+	return 0, r.err
+}
+
+// neverEnding is an endless reader of its byte.
+type neverEnding byte
+
+func (b neverEnding) Read(p []byte) (int, error) {
+	for i := range p {
+		p[i] = byte(b)
+	}
+	return len(p), nil
+}
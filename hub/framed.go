@@ -0,0 +1,301 @@
+package hub
+
+import (
+	"bufio"
+	"context"
+	"errors"
+	"io"
+	"sync"
+	"time"
+
+	"github.com/subcommands_test/cli/lib"
+)
+
+// ErrProviderClosed is returned for invocations on a closed provider.
+var ErrProviderClosed = errors.New("provider closed")
+
+// FramedCliProvider communicates with a lib.ReaderWriterProvider using the
+// framed protocol. Every invocation gets its own ID, so multiple invocations
+// can be in flight and results may arrive in any order. Chunk frames are
+// passed to InvokeStream until the response frame arrives and event frames
+// to Subscribe.
+//
+// The manifest is taken from the hello frame sent by the provider. If the
+// provider speaks another protocol version all invocations fail with
+// ErrIncompatibleProtocol.
+type FramedCliProvider struct {
+	input io.Writer
+
+	hello    chan struct{}
+	manifest *lib.Manifest
+
+	writeMu sync.Mutex
+
+	mu      sync.Mutex
+	nextID  uint64
+	pending map[uint64]*call
+	err     error
+	// failed is closed as soon as err is set
+	failed chan struct{}
+	events func(*lib.Event)
+	// subscription identifies the Subscribe call events belongs to
+	subscription uint64
+}
+
+// call is a pending invocation waiting for its frames.
+type call struct {
+	frames chan *lib.Frame
+	// done is closed when the invoker stops waiting
+	done chan struct{}
+}
+
+// NewFramedCliProvider creates a FramedCliProvider writing frames to input
+// and reading frames from output. Reading starts immediately.
+func NewFramedCliProvider(input io.Writer, output io.Reader) *FramedCliProvider {
+	prov := &FramedCliProvider{
+		input:   input,
+		hello:   make(chan struct{}),
+		pending: make(map[uint64]*call),
+		failed:  make(chan struct{}),
+	}
+	go prov.readLoop(output)
+	return prov
+}
+
+func (prov *FramedCliProvider) readLoop(output io.Reader) {
+	reader := bufio.NewReader(output)
+	for {
+		line, err := reader.ReadBytes('\n')
+		if err != nil {
+			if err == io.EOF {
+				err = ErrProviderClosed
+			}
+			prov.fail(err)
+			return
+		}
+		frame, err := lib.ParseFrame(line)
+		if err != nil {
+			// Ignore everything that isn't a frame
+			continue
+		}
+		if frame.Type == lib.FrameHello {
+			prov.greet(frame.Manifest)
+			continue
+		}
+		if frame.Type == lib.FrameEvent {
+			prov.mu.Lock()
+			events := prov.events
+			prov.mu.Unlock()
+			if events != nil && frame.Event != nil {
+				events(frame.Event)
+			}
+			continue
+		}
+		prov.mu.Lock()
+		waiting, ok := prov.pending[frame.ID]
+		if frame.Type != lib.FrameChunk {
+			delete(prov.pending, frame.ID)
+		}
+		prov.mu.Unlock()
+		if ok {
+			select {
+			case waiting.frames <- frame:
+			case <-waiting.done:
+			}
+		}
+	}
+}
+
+// greet stores the manifest of the hello frame. Only the first hello counts.
+func (prov *FramedCliProvider) greet(manifest *lib.Manifest) {
+	if manifest == nil || prov.manifest != nil {
+		return
+	}
+	prov.manifest = manifest
+	close(prov.hello)
+	if err := checkProtocol(manifest); err != nil {
+		prov.fail(err)
+	}
+}
+
+// Describe waits for the hello frame of the provider and returns its
+// manifest. It fails if the provider fails before sending one.
+func (prov *FramedCliProvider) Describe(ctx context.Context) (*lib.Manifest, error) {
+	// The manifest stays available after the provider failed
+	select {
+	case <-prov.hello:
+		return prov.manifest, nil
+	default:
+	}
+	select {
+	case <-prov.hello:
+		return prov.manifest, nil
+	case <-prov.failed:
+		prov.mu.Lock()
+		defer prov.mu.Unlock()
+		return nil, prov.err
+	case <-ctx.Done():
+		return nil, ctx.Err()
+	}
+}
+
+// fail stops all pending invocations and lets all further invocations fail with err.
+func (prov *FramedCliProvider) fail(err error) {
+	prov.mu.Lock()
+	defer prov.mu.Unlock()
+	if prov.err == nil {
+		prov.err = err
+		close(prov.failed)
+	}
+	for id := range prov.pending {
+		delete(prov.pending, id)
+	}
+}
+
+func (prov *FramedCliProvider) register() (uint64, *call, error) {
+	prov.mu.Lock()
+	defer prov.mu.Unlock()
+	if prov.err != nil {
+		return 0, nil, prov.err
+	}
+	prov.nextID++
+	waiting := &call{
+		frames: make(chan *lib.Frame, 1),
+		done:   make(chan struct{}),
+	}
+	prov.pending[prov.nextID] = waiting
+	return prov.nextID, waiting, nil
+}
+
+func (prov *FramedCliProvider) unregister(id uint64, waiting *call) {
+	prov.mu.Lock()
+	defer prov.mu.Unlock()
+	delete(prov.pending, id)
+	close(waiting.done)
+}
+
+func (prov *FramedCliProvider) write(frame *lib.Frame) error {
+	prov.writeMu.Lock()
+	defer prov.writeMu.Unlock()
+	return lib.WriteFrame(prov.input, frame)
+}
+
+// Invoke calls InvokeRich and returns the text of the response.
+func (prov *FramedCliProvider) Invoke(ctx context.Context, command string, args []string) (string, error) {
+	resp, err := prov.InvokeRich(ctx, command, args)
+	if err != nil {
+		return "", err
+	}
+	return resp.String(), nil
+}
+
+// InvokeRich calls InvokeStream and returns the messages of all chunks
+// together with the response.
+func (prov *FramedCliProvider) InvokeRich(ctx context.Context, command string, args []string) (*lib.Response, error) {
+	return collect(ctx, prov, command, args)
+}
+
+// InvokeStream sends a frame with the arguments and waits for the frame with the same ID.
+// Chunk frames arriving before are passed to chunks.
+// The deadline and invocation of ctx are sent along and if ctx is done before the result arrived
+// the provider is told to cancel the invocation.
+func (prov *FramedCliProvider) InvokeStream(ctx context.Context, command string, args []string, chunks func(*lib.Response)) (*lib.Response, error) {
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+	id, waiting, err := prov.register()
+	if err != nil {
+		return nil, err
+	}
+	defer prov.unregister(id, waiting)
+
+	frame := &lib.Frame{
+		ID:   id,
+		Args: withCommand(command, args),
+	}
+	if deadline, ok := ctx.Deadline(); ok {
+		frame.Deadline = &deadline
+	}
+	if inv, ok := lib.InvocationFromContext(ctx); ok {
+		frame.Invocation = inv
+	}
+	err = prov.write(frame)
+	if err != nil {
+		return nil, err
+	}
+
+	for {
+		select {
+		case frame := <-waiting.frames:
+			if frame.Type == lib.FrameChunk {
+				chunks(frameResponse(frame))
+				continue
+			}
+			if frame.Error != nil {
+				// The provider may notice the deadline before ctx does
+				if deadline, ok := ctx.Deadline(); ok && !time.Now().Before(deadline) {
+					return nil, context.DeadlineExceeded
+				}
+				return nil, frame.Error
+			}
+			return frameResponse(frame), nil
+		case <-prov.failed:
+			prov.mu.Lock()
+			defer prov.mu.Unlock()
+			return nil, prov.err
+		case <-ctx.Done():
+			// The invocation failed anyways, so errors while canceling can be ignored
+			prov.write(&lib.Frame{
+				ID:   id,
+				Type: lib.FrameCancel,
+			})
+			return nil, ctx.Err()
+		}
+	}
+}
+
+// frameResponse returns the structured response of the frame. Frames
+// carrying a result only are mapped to a single text message.
+func frameResponse(frame *lib.Frame) *lib.Response {
+	if frame.Response != nil {
+		return frame.Response
+	}
+	return lib.Text(frame.Result)
+}
+
+// Subscribe passes the events of event frames to events until ctx is done
+// or the provider failed. Events arriving without subscription are dropped.
+// Only one subscription is active at a time, a new one replaces the last.
+func (prov *FramedCliProvider) Subscribe(ctx context.Context, events func(*lib.Event)) error {
+	prov.mu.Lock()
+	prov.subscription++
+	subscription := prov.subscription
+	prov.events = events
+	prov.mu.Unlock()
+	defer func() {
+		prov.mu.Lock()
+		defer prov.mu.Unlock()
+		// A newer subscription may have replaced this one
+		if prov.subscription == subscription {
+			prov.events = nil
+		}
+	}()
+
+	select {
+	case <-ctx.Done():
+		return ctx.Err()
+	case <-prov.failed:
+		prov.mu.Lock()
+		defer prov.mu.Unlock()
+		return prov.err
+	}
+}
+
+// Close closes the input of the provider if possible, which stops it.
+func (prov *FramedCliProvider) Close() error {
+	prov.fail(ErrProviderClosed)
+	if closer, ok := prov.input.(io.Closer); ok {
+		return closer.Close()
+	}
+	return nil
+}
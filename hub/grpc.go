@@ -0,0 +1,323 @@
+package hub
+
+import (
+	"context"
+	"sync"
+
+	"github.com/subcommands_test/cli/lib"
+	"github.com/subcommands_test/grpc/pb"
+	"github.com/subcommands_test/grpc/provider"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/status"
+)
+
+// GrpcProvider invokes commands with the unary Handle rpc.
+type GrpcProvider struct {
+	conn   *grpc.ClientConn
+	client pb.CommandClient
+}
+
+// NewGrpcProvider creates a GrpcProvider using the connection. The
+// connection is closed with the provider.
+func NewGrpcProvider(conn *grpc.ClientConn) *GrpcProvider {
+	return &GrpcProvider{
+		conn:   conn,
+		client: pb.NewCommandClient(conn),
+	}
+}
+
+// Invoke calls InvokeRich and returns the text of the response.
+func (prov *GrpcProvider) Invoke(ctx context.Context, command string, args []string) (string, error) {
+	resp, err := prov.InvokeRich(ctx, command, args)
+	if err != nil {
+		return "", err
+	}
+	return resp.String(), nil
+}
+
+// InvokeRich calls the Handle rpc and returns the structured response.
+// The invocation of ctx is sent along.
+func (prov *GrpcProvider) InvokeRich(ctx context.Context, command string, args []string) (*lib.Response, error) {
+	resp, err := prov.client.Handle(ctx, arguments(ctx, command, args))
+	if err != nil {
+		return nil, provider.ErrorFromStatus(err)
+	}
+	return provider.ResultFromProto(resp), nil
+}
+
+// arguments creates the rpc arguments including the invocation of ctx.
+func arguments(ctx context.Context, command string, args []string) *pb.CommandArguments {
+	arg := &pb.CommandArguments{
+		Args:        args,
+		CommandName: command,
+	}
+	if inv, ok := lib.InvocationFromContext(ctx); ok {
+		arg.Invocation = provider.InvocationToProto(inv)
+	}
+	return arg
+}
+
+// Describe calls the Describe rpc.
+func (prov *GrpcProvider) Describe(ctx context.Context) (*lib.Manifest, error) {
+	return describe(ctx, prov.client)
+}
+
+func describe(ctx context.Context, client pb.CommandClient) (*lib.Manifest, error) {
+	manifest, err := client.Describe(ctx, &pb.DescribeRequest{})
+	if err != nil {
+		return nil, err
+	}
+	return provider.ManifestFromProto(manifest), nil
+}
+
+// Subscribe calls the Subscribe rpc and passes all events to events.
+func (prov *GrpcProvider) Subscribe(ctx context.Context, events func(*lib.Event)) error {
+	return subscribe(ctx, prov.client, events)
+}
+
+func subscribe(ctx context.Context, client pb.CommandClient, events func(*lib.Event)) error {
+	stream, err := client.Subscribe(ctx, &pb.SubscribeRequest{})
+	if err != nil {
+		return err
+	}
+	for {
+		event, err := stream.Recv()
+		if err != nil {
+			if ctx.Err() != nil {
+				return ctx.Err()
+			}
+			return err
+		}
+		events(provider.EventFromProto(event))
+	}
+}
+
+// Close closes the underlying connection.
+func (prov *GrpcProvider) Close() error {
+	return prov.conn.Close()
+}
+
+// GrpcStreamProvider invokes commands over a single HandleStream rpc.
+// As results are only matched by their order and the provider handles
+// the invocations of a stream one after another, the stream is replaced by
+// a new one once an invocation is abandoned, e.g. after a timeout. A stuck
+// command only blocks its own stream that way. Invocations still waiting
+// on the replaced stream fail with CodeUnavailable.
+type GrpcStreamProvider struct {
+	// ctx ends all streams
+	ctx    context.Context
+	conn   *grpc.ClientConn
+	client pb.CommandClient
+
+	// sendMu keeps the order of sent arguments and waiting invocations the
+	// same and guards stream
+	sendMu sync.Mutex
+	stream *handleStream
+}
+
+// handleStream is a single HandleStream rpc and the invocations waiting
+// for its results.
+type handleStream struct {
+	stream pb.Command_HandleStreamClient
+	cancel context.CancelFunc
+
+	mu      sync.Mutex
+	waiting []*streamCall
+	err     error
+}
+
+// errStreamReplaced fails the invocations waiting on a replaced stream.
+var errStreamReplaced = lib.Errorf(lib.CodeUnavailable, "stream was replaced after an abandoned invocation")
+
+// streamCall is an invocation waiting for its results.
+type streamCall struct {
+	results chan *pb.CommandResult
+	// done is closed when the invoker stops waiting
+	done chan struct{}
+	// failed receives the error ending the stream
+	failed chan error
+}
+
+// NewGrpcStreamProvider opens the stream on the connection. The stream
+// lives as long as ctx and the connection is closed with the provider.
+func NewGrpcStreamProvider(ctx context.Context, conn *grpc.ClientConn) (*GrpcStreamProvider, error) {
+	prov := &GrpcStreamProvider{
+		ctx:    ctx,
+		conn:   conn,
+		client: pb.NewCommandClient(conn),
+	}
+	stream, err := prov.open()
+	if err != nil {
+		return nil, err
+	}
+	prov.stream = stream
+	return prov, nil
+}
+
+// open starts a new HandleStream rpc and receives its results.
+func (prov *GrpcStreamProvider) open() (*handleStream, error) {
+	ctx, cancel := context.WithCancel(prov.ctx)
+	stream, err := prov.client.HandleStream(ctx)
+	if err != nil {
+		cancel()
+		return nil, err
+	}
+	hs := &handleStream{
+		stream: stream,
+		cancel: cancel,
+	}
+	go hs.recvLoop()
+	return hs, nil
+}
+
+// replace replaces the stream by a new one, unless that happened already.
+// If no new stream can be opened, further invocations fail with the error.
+func (prov *GrpcStreamProvider) replace(old *handleStream) {
+	prov.sendMu.Lock()
+	defer prov.sendMu.Unlock()
+	if prov.stream != old {
+		return
+	}
+	old.fail(errStreamReplaced)
+	old.cancel()
+	stream, err := prov.open()
+	if err != nil {
+		stream = &handleStream{err: err, cancel: func() {}}
+	}
+	prov.stream = stream
+}
+
+// recvLoop passes the results to the oldest waiting invocation until the
+// stream ends. The result without chunk ends the invocation.
+func (hs *handleStream) recvLoop() {
+	for {
+		resp, err := hs.stream.Recv()
+		if err != nil {
+			hs.fail(err)
+			return
+		}
+		hs.mu.Lock()
+		var waiting *streamCall
+		if len(hs.waiting) > 0 {
+			waiting = hs.waiting[0]
+			if !resp.Chunk {
+				hs.waiting = hs.waiting[1:]
+			}
+		}
+		hs.mu.Unlock()
+		if waiting != nil {
+			select {
+			case waiting.results <- resp:
+			case <-waiting.done:
+			}
+		}
+	}
+}
+
+// fail fails all waiting and following invocations of the stream.
+func (hs *handleStream) fail(err error) {
+	hs.mu.Lock()
+	defer hs.mu.Unlock()
+	if hs.err == nil {
+		hs.err = err
+	}
+	for _, waiting := range hs.waiting {
+		waiting.failed <- hs.err
+	}
+	hs.waiting = nil
+}
+
+// Invoke calls InvokeRich and returns the text of the response.
+func (prov *GrpcStreamProvider) Invoke(ctx context.Context, command string, args []string) (string, error) {
+	resp, err := prov.InvokeRich(ctx, command, args)
+	if err != nil {
+		return "", err
+	}
+	return resp.String(), nil
+}
+
+// InvokeRich calls InvokeStream and returns the messages of all chunks
+// together with the response.
+func (prov *GrpcStreamProvider) InvokeRich(ctx context.Context, command string, args []string) (*lib.Response, error) {
+	return collect(ctx, prov, command, args)
+}
+
+// InvokeStream sends the arguments and the invocation of ctx on the stream
+// and waits for the result or until ctx is done. Chunks received before
+// are passed to chunks. Invocations abandoned because ctx is done replace
+// the stream.
+func (prov *GrpcStreamProvider) InvokeStream(ctx context.Context, command string, args []string, chunks func(*lib.Response)) (*lib.Response, error) {
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+	waiting := &streamCall{
+		results: make(chan *pb.CommandResult),
+		done:    make(chan struct{}),
+		failed:  make(chan error, 1),
+	}
+	defer close(waiting.done)
+
+	prov.sendMu.Lock()
+	hs := prov.stream
+	hs.mu.Lock()
+	err := hs.err
+	if err == nil {
+		hs.waiting = append(hs.waiting, waiting)
+	}
+	hs.mu.Unlock()
+	if err == nil {
+		err = hs.stream.Send(arguments(ctx, command, args))
+		if err != nil {
+			// The stream is broken, recvLoop fails the waiting invocations
+			hs.stream.CloseSend()
+		}
+	}
+	prov.sendMu.Unlock()
+	if err != nil {
+		return nil, err
+	}
+
+	for {
+		select {
+		case resp := <-waiting.results:
+			if resp.Status != nil && resp.Status.Code != 0 {
+				return nil, provider.ErrorFromStatus(status.ErrorProto(resp.Status))
+			}
+			if !resp.Chunk {
+				return provider.ResultFromProto(resp), nil
+			}
+			chunks(provider.ResultFromProto(resp))
+		case err := <-waiting.failed:
+			return nil, err
+		case <-ctx.Done():
+			// The provider answers the next invocations of the stream only
+			// after this one, which may never happen
+			prov.replace(hs)
+			return nil, ctx.Err()
+		}
+	}
+}
+
+// Describe calls the Describe rpc besides the stream.
+func (prov *GrpcStreamProvider) Describe(ctx context.Context) (*lib.Manifest, error) {
+	return describe(ctx, prov.client)
+}
+
+// Subscribe calls the Subscribe rpc besides the stream.
+func (prov *GrpcStreamProvider) Subscribe(ctx context.Context, events func(*lib.Event)) error {
+	return subscribe(ctx, prov.client, events)
+}
+
+// Close closes the sending side of the stream and the connection.
+func (prov *GrpcStreamProvider) Close() error {
+	prov.sendMu.Lock()
+	hs := prov.stream
+	prov.sendMu.Unlock()
+	if hs.stream != nil {
+		if err := hs.stream.CloseSend(); err != nil {
+			prov.conn.Close()
+			return err
+		}
+	}
+	return prov.conn.Close()
+}
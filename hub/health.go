@@ -0,0 +1,83 @@
+package hub
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/url"
+	"strings"
+
+	"github.com/subcommands_test/grpc/provider"
+	"google.golang.org/grpc"
+	healthpb "google.golang.org/grpc/health/grpc_health_v1"
+)
+
+// ErrNotServing is returned by health checks of providers which are
+// reachable, but not able to handle invocations.
+var ErrNotServing = errors.New("provider not serving")
+
+// HealthChecker is implemented by providers able to tell whether they're
+// ready to handle invocations.
+type HealthChecker interface {
+	// Health returns nil if the provider is ready.
+	Health(ctx context.Context) error
+}
+
+// Health waits for the hello frame of the provider.
+func (prov *FramedCliProvider) Health(ctx context.Context) error {
+	_, err := prov.Describe(ctx)
+	if err != nil {
+		return err
+	}
+	prov.mu.Lock()
+	defer prov.mu.Unlock()
+	return prov.err
+}
+
+// Health checks the '/healthz' endpoint of the provider.
+func (prov *WebProvider) Health(ctx context.Context) error {
+	endpoint, err := url.Parse(prov.URL)
+	if err != nil {
+		return err
+	}
+	endpoint.Path = strings.TrimSuffix(endpoint.Path, "/") + "/healthz"
+
+	req, err := http.NewRequest(http.MethodGet, endpoint.String(), nil)
+	if err != nil {
+		return err
+	}
+	resp, err := prov.Client.Do(req.WithContext(ctx))
+	if err != nil {
+		return err
+	}
+	closeBody(resp.Body)
+	if resp.StatusCode != http.StatusOK {
+		return fmt.Errorf("%w: status %s", ErrNotServing, resp.Status)
+	}
+	return nil
+}
+
+// Health checks the status of the Command service reported by the standard
+// grpc health service.
+func (prov *GrpcProvider) Health(ctx context.Context) error {
+	return checkHealth(ctx, prov.conn, provider.ServiceName)
+}
+
+// Health checks the status of the Command service like GrpcProvider.Health.
+func (prov *GrpcStreamProvider) Health(ctx context.Context) error {
+	return checkHealth(ctx, prov.conn, provider.ServiceName)
+}
+
+func checkHealth(ctx context.Context, conn *grpc.ClientConn, service string) error {
+	resp, err := healthpb.NewHealthClient(conn).Check(ctx, &healthpb.HealthCheckRequest{
+		Service: service,
+	})
+	if err != nil {
+		return err
+	}
+	if resp.Status != healthpb.HealthCheckResponse_SERVING {
+		return fmt.Errorf("%w: status %s", ErrNotServing, resp.Status)
+	}
+	return nil
+}
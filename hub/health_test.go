@@ -0,0 +1,106 @@
+package hub
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"sync"
+	"testing"
+
+	"github.com/subcommands_test/grpc/provider"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/health"
+	healthpb "google.golang.org/grpc/health/grpc_health_v1"
+)
+
+func TestHealth(t *testing.T) {
+	healthy := true
+	web := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != "/healthz" || !healthy {
+			w.WriteHeader(http.StatusServiceUnavailable)
+		}
+	}))
+	defer web.Close()
+	webProv := NewWebProvider(web.URL)
+	defer webProv.Close()
+
+	srv := grpc.NewServer()
+	healthSrv := health.NewServer()
+	healthSrv.SetServingStatus(provider.ServiceName, healthpb.HealthCheckResponse_SERVING)
+	healthpb.RegisterHealthServer(srv, healthSrv)
+	defer srv.Stop()
+	grpcProv := NewGrpcProvider(dialBufconn(t, srv))
+	defer grpcProv.Close()
+
+	for _, checker := range []HealthChecker{webProv, grpcProv} {
+		if err := checker.Health(context.Background()); err != nil {
+			t.Error(err)
+		}
+	}
+
+	healthy = false
+	healthSrv.Shutdown()
+	for _, checker := range []HealthChecker{webProv, grpcProv} {
+		if err := checker.Health(context.Background()); !errors.Is(err, ErrNotServing) {
+			t.Errorf("expected ErrNotServing, got %v", err)
+		}
+	}
+}
+
+type fakeChecker struct {
+	mu  sync.Mutex
+	err error
+}
+
+func (checker *fakeChecker) Health(ctx context.Context) error {
+	checker.mu.Lock()
+	defer checker.mu.Unlock()
+	return checker.err
+}
+
+func (checker *fakeChecker) set(err error) {
+	checker.mu.Lock()
+	defer checker.mu.Unlock()
+	checker.err = err
+}
+
+func TestProber(t *testing.T) {
+	var changes []bool
+	prober := &Prober{
+		FailureThreshold: 2,
+		OnChange: func(name string, healthy bool, err error) {
+			changes = append(changes, healthy)
+		},
+	}
+	checker := &fakeChecker{}
+	prober.Add("grpc", checker)
+	if prober.Healthy("unknown") {
+		t.Error("unknown providers must be unhealthy")
+	}
+
+	prober.Probe(context.Background())
+	if !prober.Healthy("grpc") {
+		t.Error("expected healthy provider")
+	}
+
+	checker.set(ErrNotServing)
+	prober.Probe(context.Background())
+	if !prober.Healthy("grpc") {
+		t.Error("provider marked unhealthy before reaching the threshold")
+	}
+	prober.Probe(context.Background())
+	if prober.Healthy("grpc") {
+		t.Error("expected unhealthy provider")
+	}
+
+	checker.set(nil)
+	prober.Probe(context.Background())
+	if !prober.Healthy("grpc") {
+		t.Error("expected provider to recover")
+	}
+	if !reflect.DeepEqual(changes, []bool{false, true}) {
+		t.Errorf("invalid changes %v", changes)
+	}
+}
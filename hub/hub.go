@@ -0,0 +1,127 @@
+// Package hub contains the hub side of the command providers. The hub
+// delegates command invocations to Providers without knowing which
+// transport is used to reach them.
+package hub
+
+import (
+	"context"
+	"errors"
+	"fmt"
+
+	"github.com/subcommands_test/cli/lib"
+)
+
+var (
+	// ErrIncompatibleProtocol is returned for providers speaking another protocol version than the hub.
+	ErrIncompatibleProtocol = errors.New("incompatible protocol version")
+	// ErrNoManifest is returned by providers which can't describe themselves.
+	ErrNoManifest = errors.New("provider has no manifest")
+)
+
+// Provider is the client to a single CommandProvider.
+type Provider interface {
+	// Invoke delegates a command invocation to the provider and returns its result.
+	// Providers with multiple commands route the invocation by the command name.
+	// Without name the provider's default command is invoked. The name is passed
+	// as first argument, so Invoke(ctx, "hello", []string{"Kevin"}) is the same as
+	// Invoke(ctx, "", []string{"hello", "Kevin"}).
+	// The invocation set with lib.WithInvocation is passed along if the
+	// transport supports it.
+	Invoke(ctx context.Context, command string, args []string) (string, error)
+	// Describe returns the manifest of the provider.
+	Describe(ctx context.Context) (*lib.Manifest, error)
+	// Close releases all resources held by the client.
+	Close() error
+}
+
+// RichProvider is a Provider able to return structured responses.
+type RichProvider interface {
+	Provider
+	// InvokeRich delegates a command invocation like Invoke but returns the
+	// structured response of the provider.
+	InvokeRich(ctx context.Context, command string, args []string) (*lib.Response, error)
+}
+
+// InvokeRich invokes the provider with InvokeRich if it's a RichProvider.
+// Results of other providers are mapped to a single text message.
+func InvokeRich(ctx context.Context, prov Provider, command string, args []string) (*lib.Response, error) {
+	if rich, ok := prov.(RichProvider); ok {
+		return rich.InvokeRich(ctx, command, args)
+	}
+	result, err := prov.Invoke(ctx, command, args)
+	if err != nil {
+		return nil, err
+	}
+	return lib.Text(result), nil
+}
+
+// StreamProvider is a RichProvider able to pass on chunks of the response
+// as they arrive, e.g. to forward progress to the chat.
+type StreamProvider interface {
+	RichProvider
+	// InvokeStream delegates a command invocation like InvokeRich. Chunks
+	// sent by the command before it returns are passed to chunks in order.
+	// The returned response marks the end and may be empty.
+	InvokeStream(ctx context.Context, command string, args []string, chunks func(*lib.Response)) (*lib.Response, error)
+}
+
+// InvokeStream invokes the provider with InvokeStream if it's a
+// StreamProvider. Other providers are invoked with InvokeRich, so chunks
+// isn't called at all.
+func InvokeStream(ctx context.Context, prov Provider, command string, args []string, chunks func(*lib.Response)) (*lib.Response, error) {
+	if stream, ok := prov.(StreamProvider); ok {
+		return stream.InvokeStream(ctx, command, args, chunks)
+	}
+	return InvokeRich(ctx, prov, command, args)
+}
+
+// collect invokes the provider with InvokeStream and returns the messages
+// of all chunks together with the final response.
+func collect(ctx context.Context, prov StreamProvider, command string, args []string) (*lib.Response, error) {
+	collected := &lib.Response{}
+	resp, err := prov.InvokeStream(ctx, command, args, collected.Append)
+	if err != nil {
+		return nil, err
+	}
+	collected.Append(resp)
+	return collected, nil
+}
+
+// InvokeLine splits the line into arguments with lib.SplitArgs and invokes
+// the provider with them. Lines which can't be split aren't delegated.
+func InvokeLine(ctx context.Context, prov Provider, line string) (string, error) {
+	args, err := lib.SplitArgs(line)
+	if err != nil {
+		return "", err
+	}
+	return prov.Invoke(ctx, "", args)
+}
+
+// withCommand prepends the command name to the arguments if set.
+func withCommand(command string, args []string) []string {
+	if command == "" {
+		return args
+	}
+	return append([]string{command}, args...)
+}
+
+// Handshake asks the provider for its manifest and checks if the hub is able
+// to speak its protocol version. Providers failing the handshake must not be
+// used to route invocations to.
+func Handshake(ctx context.Context, prov Provider) (*lib.Manifest, error) {
+	manifest, err := prov.Describe(ctx)
+	if err != nil {
+		return nil, err
+	}
+	if err := checkProtocol(manifest); err != nil {
+		return nil, err
+	}
+	return manifest, nil
+}
+
+func checkProtocol(manifest *lib.Manifest) error {
+	if manifest.ProtocolVersion != lib.ProtocolVersion {
+		return fmt.Errorf("%w: provider '%s' speaks version %d, hub speaks %d", ErrIncompatibleProtocol, manifest.Name, manifest.ProtocolVersion, lib.ProtocolVersion)
+	}
+	return nil
+}
@@ -0,0 +1,650 @@
+package hub
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/subcommands_test/cli/lib"
+	"github.com/subcommands_test/grpc/pb"
+	"github.com/subcommands_test/grpc/provider"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/test/bufconn"
+)
+
+func testInvoke(t *testing.T, prov Provider) {
+	for _, name := range []string{"Kevin", "Mary"} {
+		result, err := prov.Invoke(context.Background(), "", []string{name})
+		if err != nil {
+			t.Fatal(err)
+		}
+		if expected := fmt.Sprintf("Hello, %s!", name); result != expected {
+			t.Errorf("invalid result '%s', expected '%s'", result, expected)
+		}
+	}
+}
+
+func startCli(framed bool) (io.WriteCloser, io.Reader, <-chan struct{}) {
+	inReader, inWriter := io.Pipe()
+	outReader, outWriter := io.Pipe()
+	provider := &lib.ReaderWriterProvider{
+		Input:       inReader,
+		Output:      outWriter,
+		Framed:      framed,
+		HandlerFunc: lib.HelloProvider,
+	}
+	return inWriter, outReader, provider.Start()
+}
+
+func TestCliProvider(t *testing.T) {
+	in, out, done := startCli(false)
+	prov := NewCliProvider(in, out)
+	testInvoke(t, prov)
+	// A multi-line argument would be sent as two invocations, which mixes
+	// up the results of the following ones
+	_, err := prov.Invoke(context.Background(), "", []string{"Mary\nAnn"})
+	if lib.AsError(err).Code != lib.CodeInvalidArgument {
+		t.Errorf("expected invalid argument error, got %v", err)
+	}
+	testInvoke(t, prov)
+	if err := prov.Close(); err != nil {
+		t.Fatal(err)
+	}
+	<-done
+}
+
+func TestFramedCliProvider(t *testing.T) {
+	in, out, done := startCli(true)
+	prov := NewFramedCliProvider(in, out)
+	testInvoke(t, prov)
+	if err := prov.Close(); err != nil {
+		t.Fatal(err)
+	}
+	<-done
+
+	_, err := prov.Invoke(context.Background(), "", []string{"Kevin"})
+	if err != ErrProviderClosed {
+		t.Errorf("expected ErrProviderClosed, got %v", err)
+	}
+}
+
+func TestFramedCliProvider_OutOfOrder(t *testing.T) {
+	inReader, inWriter := io.Pipe()
+	outReader, outWriter := io.Pipe()
+	prov := NewFramedCliProvider(inWriter, outReader)
+	defer prov.Close()
+
+	// Answer both invocations in reverse order
+	go func() {
+		reader := bufio.NewReader(inReader)
+		var frames []*lib.Frame
+		for len(frames) < 2 {
+			line, err := reader.ReadBytes('\n')
+			if err != nil {
+				return
+			}
+			frame, err := lib.ParseFrame(line)
+			if err != nil {
+				return
+			}
+			frames = append(frames, frame)
+		}
+		for i := len(frames) - 1; i >= 0; i-- {
+			lib.WriteFrame(outWriter, &lib.Frame{ID: frames[i].ID, Result: frames[i].Args[0]})
+		}
+	}()
+
+	var wg sync.WaitGroup
+	for _, name := range []string{"first", "second"} {
+		wg.Add(1)
+		go func(name string) {
+			defer wg.Done()
+			result, err := prov.Invoke(context.Background(), "", []string{name})
+			if err != nil {
+				t.Error(err)
+				return
+			}
+			if result != name {
+				t.Errorf("invalid result '%s', expected '%s'", result, name)
+			}
+		}(name)
+	}
+	wg.Wait()
+}
+
+func TestWebProvider(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		fmt.Fprintf(w, "Hello, %s!", r.URL.Query().Get("params"))
+	}))
+	defer srv.Close()
+
+	prov := NewWebProvider(srv.URL)
+	defer prov.Close()
+	testInvoke(t, prov)
+}
+
+func dialBufconn(t *testing.T, srv *grpc.Server) *grpc.ClientConn {
+	lis := bufconn.Listen(1024 * 1024)
+	go srv.Serve(lis)
+
+	conn, err := grpc.Dial("bufconn", grpc.WithInsecure(), grpc.WithContextDialer(func(ctx context.Context, _ string) (net.Conn, error) {
+		return lis.Dial()
+	}))
+	if err != nil {
+		t.Fatal(err)
+	}
+	return conn
+}
+
+func TestGrpcProvider(t *testing.T) {
+	srv := grpc.NewServer()
+	pb.RegisterCommandServer(srv, &provider.CommandProviderServer{})
+	defer srv.Stop()
+	prov := NewGrpcProvider(dialBufconn(t, srv))
+	defer prov.Close()
+	testInvoke(t, prov)
+}
+
+func TestGrpcStreamProvider(t *testing.T) {
+	srv := grpc.NewServer()
+	pb.RegisterCommandServer(srv, &provider.CommandProviderServer{})
+	defer srv.Stop()
+	prov, err := NewGrpcStreamProvider(context.Background(), dialBufconn(t, srv))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer prov.Close()
+	testInvoke(t, prov)
+}
+
+func TestFramedCliProvider_Cancel(t *testing.T) {
+	inReader, inWriter := io.Pipe()
+	outReader, outWriter := io.Pipe()
+	canceled := make(chan bool, 1)
+	provider := &lib.ReaderWriterProvider{
+		Input:  inReader,
+		Output: outWriter,
+		Framed: true,
+		ContextHandler: lib.ContextCommandFunc(func(ctx context.Context, args []string) (string, error) {
+			_, hasDeadline := ctx.Deadline()
+			<-ctx.Done()
+			canceled <- hasDeadline
+			return "", ctx.Err()
+		}),
+	}
+	provider.Start()
+
+	prov := NewFramedCliProvider(inWriter, outReader)
+	defer prov.Close()
+
+	ctx, cancel := context.WithTimeout(context.Background(), 50*time.Millisecond)
+	defer cancel()
+	_, err := prov.Invoke(ctx, "", []string{"Kevin"})
+	if err != context.DeadlineExceeded {
+		t.Errorf("expected context.DeadlineExceeded, got %v", err)
+	}
+
+	select {
+	case hasDeadline := <-canceled:
+		if !hasDeadline {
+			t.Error("deadline wasn't propagated to the handler")
+		}
+	case <-time.After(time.Second):
+		t.Fatal("handler wasn't canceled")
+	}
+}
+
+func TestInvokeLine(t *testing.T) {
+	in, out, done := startCli(false)
+	prov := NewCliProvider(in, out)
+
+	result, err := InvokeLine(context.Background(), prov, `  "Mary Ann"   Kevin`)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if result != "Hello, Mary Ann!" {
+		t.Errorf("invalid result '%s'", result)
+	}
+	_, err = InvokeLine(context.Background(), prov, `"Mary Ann`)
+	if err != lib.ErrUnterminatedQuote {
+		t.Errorf("expected lib.ErrUnterminatedQuote, got %v", err)
+	}
+
+	prov.Close()
+	<-done
+}
+
+func testCommands(t *testing.T, prov Provider) {
+	tests := []struct {
+		command  string
+		args     []string
+		expected string
+	}{
+		{command: "echo", args: []string{"a", "b"}, expected: "a b"},
+		{command: "hello", args: []string{"Kevin"}, expected: "Hello, Kevin!"},
+		{command: "", args: []string{"echo", "c"}, expected: "c"},
+		{command: "", args: []string{"Kevin"}, expected: "Hello, Kevin!"},
+	}
+	for _, test := range tests {
+		result, err := prov.Invoke(context.Background(), test.command, test.args)
+		if err != nil {
+			t.Error(err)
+			continue
+		}
+		if result != test.expected {
+			t.Errorf("%s %v: invalid result '%s', expected '%s'", test.command, test.args, result, test.expected)
+		}
+	}
+	_, err := prov.Invoke(context.Background(), "roll", nil)
+	if lib.AsError(err).Code != lib.CodeNotFound {
+		t.Errorf("expected not found error, got %v", err)
+	}
+}
+
+// testRegistry greets Kevin without command name and fails for unknown commands.
+func testRegistry() *lib.Registry {
+	reg := lib.DefaultRegistry()
+	reg.Fallback = lib.ContextCommandFunc(func(ctx context.Context, args []string) (string, error) {
+		if len(args) > 0 && args[0] == "Kevin" {
+			return lib.HelloProvider(args), nil
+		}
+		return "", lib.Errorf(lib.CodeNotFound, "unknown command")
+	})
+	return reg
+}
+
+func TestFramedCliProvider_Commands(t *testing.T) {
+	inReader, inWriter := io.Pipe()
+	outReader, outWriter := io.Pipe()
+	provider := &lib.ReaderWriterProvider{
+		Input:          inReader,
+		Output:         outWriter,
+		Framed:         true,
+		ContextHandler: testRegistry(),
+	}
+	provider.Start()
+
+	prov := NewFramedCliProvider(inWriter, outReader)
+	defer prov.Close()
+	testCommands(t, prov)
+}
+
+func TestGrpcProvider_Commands(t *testing.T) {
+	srv := grpc.NewServer()
+	pb.RegisterCommandServer(srv, &provider.CommandProviderServer{RichHandler: testRegistry()})
+	defer srv.Stop()
+
+	prov := NewGrpcProvider(dialBufconn(t, srv))
+	defer prov.Close()
+	testCommands(t, prov)
+}
+
+func TestHandshake(t *testing.T) {
+	manifest := lib.NewManifest("test", lib.Version, lib.HelloInfo)
+
+	inReader, inWriter := io.Pipe()
+	outReader, outWriter := io.Pipe()
+	rw := &lib.ReaderWriterProvider{
+		Input:       inReader,
+		Output:      outWriter,
+		Framed:      true,
+		Manifest:    manifest,
+		HandlerFunc: lib.HelloProvider,
+	}
+	rw.Start()
+	cli := NewFramedCliProvider(inWriter, outReader)
+	defer cli.Close()
+
+	srv := grpc.NewServer()
+	pb.RegisterCommandServer(srv, &provider.CommandProviderServer{Manifest: manifest})
+	defer srv.Stop()
+	grpcProv := NewGrpcProvider(dialBufconn(t, srv))
+	defer grpcProv.Close()
+
+	web := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		json.NewEncoder(w).Encode(manifest)
+	}))
+	defer web.Close()
+	webProv := NewWebProvider(web.URL)
+	defer webProv.Close()
+
+	for _, prov := range []Provider{cli, grpcProv, webProv} {
+		described, err := Handshake(context.Background(), prov)
+		if err != nil {
+			t.Error(err)
+			continue
+		}
+		if !reflect.DeepEqual(described, manifest) {
+			t.Errorf("invalid manifest %+v, expected %+v", *described, *manifest)
+		}
+	}
+}
+
+func TestHandshake_Incompatible(t *testing.T) {
+	manifest := lib.NewManifest("test", lib.Version)
+	manifest.ProtocolVersion = lib.ProtocolVersion + 1
+
+	inReader, inWriter := io.Pipe()
+	outReader, outWriter := io.Pipe()
+	provider := &lib.ReaderWriterProvider{
+		Input:    inReader,
+		Output:   outWriter,
+		Framed:   true,
+		Manifest: manifest,
+	}
+	provider.Start()
+	prov := NewFramedCliProvider(inWriter, outReader)
+	defer prov.Close()
+
+	_, err := Handshake(context.Background(), prov)
+	if !errors.Is(err, ErrIncompatibleProtocol) {
+		t.Errorf("expected ErrIncompatibleProtocol, got %v", err)
+	}
+	_, err = prov.Invoke(context.Background(), "", []string{"Kevin"})
+	if !errors.Is(err, ErrIncompatibleProtocol) {
+		t.Errorf("expected invocations to be refused, got %v", err)
+	}
+}
+
+func TestHandshake_Closed(t *testing.T) {
+	outReader, outWriter := io.Pipe()
+	prov := NewFramedCliProvider(ioutil.Discard, outReader)
+	defer prov.Close()
+	// The provider exits without sending a hello frame
+	outWriter.Close()
+
+	done := make(chan error, 1)
+	go func() {
+		_, err := Handshake(context.Background(), prov)
+		done <- err
+	}()
+	select {
+	case err := <-done:
+		if !errors.Is(err, ErrProviderClosed) {
+			t.Errorf("expected ErrProviderClosed, got %v", err)
+		}
+	case <-time.After(time.Second):
+		t.Fatal("handshake is still waiting for the hello frame of a closed provider")
+	}
+	if err := prov.Health(context.Background()); !errors.Is(err, ErrProviderClosed) {
+		t.Errorf("expected closed provider to be unhealthy, got %v", err)
+	}
+}
+
+func richRegistry() *lib.Registry {
+	reg := lib.NewRegistry()
+	reg.RegisterFunc("hello", lib.HelloProvider)
+	reg.RegisterRich(lib.CommandInfo{Name: "rich"}, lib.RichCommandFunc(func(ctx context.Context, args []string) (*lib.Response, error) {
+		resp := &lib.Response{}
+		resp.Reply("Hello, Kevin!").Mention("42", "Kevin")
+		resp.Broadcast("Kevin joined").Attach(lib.Attachment{Name: "kevin.txt", Data: []byte("Kevin")})
+		return resp, nil
+	}))
+	reg.RegisterRich(lib.CommandInfo{Name: "silent"}, lib.RichCommandFunc(func(ctx context.Context, args []string) (*lib.Response, error) {
+		return lib.NoReply(), nil
+	}))
+	return reg
+}
+
+func testRich(t *testing.T, prov Provider) {
+	resp, err := InvokeRich(context.Background(), prov, "hello", []string{"Kevin"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !resp.IsText() || resp.String() != "Hello, Kevin!" {
+		t.Errorf("invalid text response %+v", resp)
+	}
+
+	resp, err = InvokeRich(context.Background(), prov, "rich", nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	expected := []*lib.Message{
+		{Text: "Hello, Kevin!", Target: lib.TargetReply, Mentions: []lib.Mention{{UserID: "42", Name: "Kevin"}}},
+		{Text: "Kevin joined", Target: lib.TargetBroadcast, Attachments: []lib.Attachment{{Name: "kevin.txt", Data: []byte("Kevin")}}},
+	}
+	if !reflect.DeepEqual(resp.Messages, expected) {
+		t.Errorf("invalid rich response %+v", resp.Messages)
+	}
+
+	resp, err = InvokeRich(context.Background(), prov, "silent", nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(resp.Messages) != 0 {
+		t.Errorf("expected no reply, got %+v", resp.Messages)
+	}
+
+	// Plain invocations get the text of all messages
+	result, err := prov.Invoke(context.Background(), "rich", nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if result != "Hello, Kevin!\nKevin joined" {
+		t.Errorf("invalid text '%s'", result)
+	}
+}
+
+func TestRich(t *testing.T) {
+	inReader, inWriter := io.Pipe()
+	outReader, outWriter := io.Pipe()
+	rw := &lib.ReaderWriterProvider{
+		Input:       inReader,
+		Output:      outWriter,
+		Framed:      true,
+		RichHandler: richRegistry(),
+	}
+	rw.Start()
+	framed := NewFramedCliProvider(inWriter, outReader)
+	defer framed.Close()
+	testRich(t, framed)
+
+	srv := grpc.NewServer()
+	pb.RegisterCommandServer(srv, &provider.CommandProviderServer{RichHandler: richRegistry()})
+	defer srv.Stop()
+	unary := NewGrpcProvider(dialBufconn(t, srv))
+	defer unary.Close()
+	testRich(t, unary)
+	stream, err := NewGrpcStreamProvider(context.Background(), dialBufconn(t, srv))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer stream.Close()
+	testRich(t, stream)
+
+	reg := richRegistry()
+	web := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		args := append([]string{r.URL.Path[1:]}, r.URL.Query()["params"]...)
+		resp, err := reg.HandleRich(r.Context(), args)
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+			return
+		}
+		if strings.Contains(r.Header.Get("Accept"), "application/json") {
+			w.Header().Set("Content-Type", "application/json")
+			json.NewEncoder(w).Encode(resp)
+			return
+		}
+		fmt.Fprint(w, resp)
+	}))
+	defer web.Close()
+	webProv := NewWebProvider(web.URL)
+	defer webProv.Close()
+	testRich(t, webProv)
+}
+
+// whoami answers with the invocation it received.
+var whoami = lib.ContextCommandFunc(func(ctx context.Context, args []string) (string, error) {
+	inv, ok := lib.InvocationFromContext(ctx)
+	if !ok {
+		return "", lib.Errorf(lib.CodeInvalidArgument, "invocation missing")
+	}
+	return fmt.Sprintf("%s (%s) in %s on %s, %s, %s, moderator=%v, %d", inv.SenderName, inv.SenderID, inv.Channel, inv.Platform, inv.MessageID, inv.Roles, inv.HasRole("Moderator"), inv.Timestamp.Unix()), nil
+})
+
+func TestInvocation(t *testing.T) {
+	reg := lib.NewRegistry()
+	reg.Register("whoami", whoami)
+	inv := &lib.Invocation{
+		SenderID:   "42",
+		SenderName: "Kevin",
+		Channel:    "#general",
+		Platform:   "twitch",
+		Roles:      []string{"moderator", "subscriber"},
+		MessageID:  "m1",
+		Timestamp:  time.Unix(1581000000, 0),
+	}
+	expected := "Kevin (42) in #general on twitch, m1, [moderator subscriber], moderator=true, 1581000000"
+
+	inReader, inWriter := io.Pipe()
+	outReader, outWriter := io.Pipe()
+	rw := &lib.ReaderWriterProvider{
+		Input:          inReader,
+		Output:         outWriter,
+		Framed:         true,
+		ContextHandler: reg,
+	}
+	rw.Start()
+	framed := NewFramedCliProvider(inWriter, outReader)
+	defer framed.Close()
+
+	srv := grpc.NewServer()
+	pb.RegisterCommandServer(srv, &provider.CommandProviderServer{RichHandler: reg})
+	defer srv.Stop()
+	unary := NewGrpcProvider(dialBufconn(t, srv))
+	defer unary.Close()
+	stream, err := NewGrpcStreamProvider(context.Background(), dialBufconn(t, srv))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer stream.Close()
+
+	web := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		ctx := r.Context()
+		if inv, ok := lib.InvocationFromHeader(r.Header); ok {
+			ctx = lib.WithInvocation(ctx, inv)
+		}
+		result, err := reg.Handle(ctx, []string{r.URL.Path[1:]})
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+		fmt.Fprint(w, result)
+	}))
+	defer web.Close()
+	webProv := NewWebProvider(web.URL)
+	defer webProv.Close()
+
+	for _, prov := range []Provider{framed, unary, stream, webProv} {
+		result, err := prov.Invoke(lib.WithInvocation(context.Background(), inv), "whoami", nil)
+		if err != nil {
+			t.Errorf("%T: %v", prov, err)
+			continue
+		}
+		if result != expected {
+			t.Errorf("%T: invalid result '%s'", prov, result)
+		}
+		if _, err := prov.Invoke(context.Background(), "whoami", nil); err == nil {
+			t.Errorf("%T: invocation passed without being set", prov)
+		}
+	}
+}
+
+func TestStream(t *testing.T) {
+	reg := lib.NewRegistry()
+	reg.RegisterFunc("hello", lib.HelloProvider)
+	reg.RegisterRich(lib.CommandInfo{Name: "countdown"}, lib.RichCommandFunc(func(ctx context.Context, args []string) (*lib.Response, error) {
+		for _, n := range []string{"3", "2", "1"} {
+			if err := lib.SendChunk(ctx, lib.Text(n)); err != nil {
+				return nil, err
+			}
+		}
+		return lib.Text("Go!"), nil
+	}))
+
+	inReader, inWriter := io.Pipe()
+	outReader, outWriter := io.Pipe()
+	rw := &lib.ReaderWriterProvider{
+		Input:       inReader,
+		Output:      outWriter,
+		Framed:      true,
+		RichHandler: reg,
+	}
+	rw.Start()
+	framed := NewFramedCliProvider(inWriter, outReader)
+	defer framed.Close()
+
+	srv := grpc.NewServer()
+	pb.RegisterCommandServer(srv, &provider.CommandProviderServer{RichHandler: reg})
+	defer srv.Stop()
+	unary := NewGrpcProvider(dialBufconn(t, srv))
+	defer unary.Close()
+	stream, err := NewGrpcStreamProvider(context.Background(), dialBufconn(t, srv))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer stream.Close()
+
+	web := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		args, _ := lib.SplitArgs(r.URL.Query().Get("params"))
+		args = append([]string{r.URL.Path[1:]}, args...)
+		if !strings.Contains(r.Header.Get("Accept"), "application/x-ndjson") {
+			resp, _ := lib.Collect(reg).HandleRich(r.Context(), args)
+			fmt.Fprint(w, resp)
+			return
+		}
+		w.Header().Set("Content-Type", "application/x-ndjson")
+		ctx := lib.WithChunks(r.Context(), func(chunk *lib.Response) error {
+			frame := lib.NewResponseFrame(0, chunk, nil)
+			frame.Type = lib.FrameChunk
+			return lib.WriteFrame(w, frame)
+		})
+		resp, err := reg.HandleRich(ctx, args)
+		lib.WriteFrame(w, lib.NewResponseFrame(0, resp, err))
+	}))
+	defer web.Close()
+	webProv := NewWebProvider(web.URL)
+	defer webProv.Close()
+
+	for _, prov := range []StreamProvider{framed, stream, webProv} {
+		var chunks []string
+		resp, err := InvokeStream(context.Background(), prov, "countdown", nil, func(chunk *lib.Response) {
+			chunks = append(chunks, chunk.String())
+		})
+		if err != nil {
+			t.Errorf("%T: %v", prov, err)
+			continue
+		}
+		if resp.String() != "Go!" || !reflect.DeepEqual(chunks, []string{"3", "2", "1"}) {
+			t.Errorf("%T: invalid chunks %v and response '%s'", prov, chunks, resp)
+		}
+
+		// Responses without chunks end right away
+		resp, err = InvokeStream(context.Background(), prov, "hello", []string{"Kevin"}, func(chunk *lib.Response) {
+			t.Errorf("%T: unexpected chunk %s", prov, chunk)
+		})
+		if err != nil || resp.String() != "Hello, Kevin!" {
+			t.Errorf("%T: invalid response '%v' (%v)", prov, resp, err)
+		}
+	}
+
+	// Providers without streaming collect the chunks
+	for _, prov := range []Provider{framed, unary, stream, webProv} {
+		result, err := prov.Invoke(context.Background(), "countdown", nil)
+		if err != nil || result != "3\n2\n1\nGo!" {
+			t.Errorf("%T: invalid result '%s' (%v)", prov, result, err)
+		}
+	}
+}
@@ -0,0 +1,137 @@
+package hub
+
+import (
+	"context"
+	"sync"
+	"time"
+)
+
+// Prober periodically checks the health of providers. A provider is marked
+// unhealthy after FailureThreshold consecutive failed checks and healthy
+// again after the first successful one. Providers are assumed to be healthy
+// until the first check failed.
+type Prober struct {
+	// Interval between two rounds of checks. Defaults to 5s.
+	Interval time.Duration
+	// Timeout of a single check. Defaults to one second.
+	Timeout time.Duration
+	// FailureThreshold is the number of consecutive failures before a
+	// provider is marked unhealthy. Defaults to one.
+	FailureThreshold int
+	// OnChange is called whenever a provider is marked healthy or unhealthy.
+	OnChange func(name string, healthy bool, err error)
+
+	mu      sync.Mutex
+	targets map[string]*probeTarget
+}
+
+type probeTarget struct {
+	checker  HealthChecker
+	healthy  bool
+	failures int
+}
+
+// Add starts probing the checker under the name, replacing any checker
+// added with the same name before.
+func (prober *Prober) Add(name string, checker HealthChecker) {
+	prober.mu.Lock()
+	defer prober.mu.Unlock()
+	if prober.targets == nil {
+		prober.targets = make(map[string]*probeTarget)
+	}
+	prober.targets[name] = &probeTarget{
+		checker: checker,
+		healthy: true,
+	}
+}
+
+// Remove stops probing the checker with the name.
+func (prober *Prober) Remove(name string) {
+	prober.mu.Lock()
+	defer prober.mu.Unlock()
+	delete(prober.targets, name)
+}
+
+// Healthy reports whether the provider with the name is healthy.
+// Unknown providers are unhealthy.
+func (prober *Prober) Healthy(name string) bool {
+	prober.mu.Lock()
+	defer prober.mu.Unlock()
+	target, ok := prober.targets[name]
+	return ok && target.healthy
+}
+
+// Probe checks all providers once, concurrently.
+func (prober *Prober) Probe(ctx context.Context) {
+	timeout := prober.Timeout
+	if timeout <= 0 {
+		timeout = time.Second
+	}
+
+	prober.mu.Lock()
+	targets := make(map[string]*probeTarget, len(prober.targets))
+	for name, target := range prober.targets {
+		targets[name] = target
+	}
+	prober.mu.Unlock()
+
+	var wg sync.WaitGroup
+	for name, target := range targets {
+		wg.Add(1)
+		go func(name string, target *probeTarget) {
+			defer wg.Done()
+			checkCtx, cancel := context.WithTimeout(ctx, timeout)
+			defer cancel()
+			prober.record(name, target, target.checker.Health(checkCtx))
+		}(name, target)
+	}
+	wg.Wait()
+}
+
+func (prober *Prober) record(name string, target *probeTarget, err error) {
+	threshold := prober.FailureThreshold
+	if threshold < 1 {
+		threshold = 1
+	}
+
+	prober.mu.Lock()
+	if prober.targets[name] != target {
+		// Removed or replaced while checking
+		prober.mu.Unlock()
+		return
+	}
+	wasHealthy := target.healthy
+	if err == nil {
+		target.failures = 0
+		target.healthy = true
+	} else {
+		target.failures++
+		if target.failures >= threshold {
+			target.healthy = false
+		}
+	}
+	changed := wasHealthy != target.healthy
+	prober.mu.Unlock()
+
+	if changed && prober.OnChange != nil {
+		prober.OnChange(name, !wasHealthy, err)
+	}
+}
+
+// Run probes all providers every Interval until ctx is done.
+func (prober *Prober) Run(ctx context.Context) {
+	interval := prober.Interval
+	if interval <= 0 {
+		interval = 5 * time.Second
+	}
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for {
+		prober.Probe(ctx)
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
@@ -0,0 +1,343 @@
+package hub
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sort"
+	"strings"
+	"sync"
+
+	"github.com/subcommands_test/cli/lib"
+)
+
+// ErrNotCommand is returned by Router.Route for messages without prefix.
+var ErrNotCommand = errors.New("message isn't a command")
+
+// ConflictError is returned for names registered by multiple providers or
+// used as command and alias at the same time.
+type ConflictError struct {
+	Name string
+	// Owner is the provider owning the name, or 'alias' if it's an alias.
+	Owner string
+}
+
+func (err *ConflictError) Error() string {
+	return fmt.Sprintf("name '%s' is already used by %s", err.Name, err.Owner)
+}
+
+// Router maps chat messages like '!hi Kevin' to the provider owning the
+// command. Messages start with a prefix followed by the name of a command
+// or an alias and the arguments, which are split with lib.SplitArgs. Names
+// are matched case-insensitive. Every channel may have its own prefix and
+// aliases, which take precedence over the ones of the router.
+//
+// Unknown commands fail with CodeNotFound and a suggestion of the most
+// similar known name.
+type Router struct {
+	// Prefix of commands in all channels without own prefix. Defaults to '!'.
+	Prefix string
+	// MaxDistance is the edit distance up to which a name is suggested for
+	// an unknown command. Defaults to 2, a negative value disables suggestions.
+	MaxDistance int
+	// NotFound returns the message for an unknown command, the suggestion
+	// is empty if there's none. Both include the prefix.
+	NotFound func(command, suggestion string) string
+
+	mu       sync.RWMutex
+	commands map[string]*route
+	// aliases maps channels to their aliases, the ones of the router have
+	// the empty channel
+	aliases  map[string]map[string]string
+	prefixes map[string]string
+}
+
+type route struct {
+	owner   string
+	prov    Provider
+	command string
+	// named passes the command name to the provider to route by
+	named bool
+}
+
+// Register routes the commands to the provider with the name. Commands
+// registered for the provider before are replaced. If a command is owned by
+// another provider or used as alias nothing is registered and a
+// *ConflictError is returned.
+//
+// Providers owning several commands get the command name as first argument
+// to route by. A single command is invoked with the remaining arguments
+// only, as single handlers like cliprov would take the name for an
+// argument. Use RegisterNamed for providers routing by name anyway.
+func (router *Router) Register(name string, prov Provider, commands ...string) error {
+	return router.register(name, prov, len(commands) > 1, commands)
+}
+
+// RegisterNamed routes the commands like Register but always passes the
+// command name, e.g. to a provider started with -multi owning a single
+// command of its registry.
+func (router *Router) RegisterNamed(name string, prov Provider, commands ...string) error {
+	return router.register(name, prov, true, commands)
+}
+
+func (router *Router) register(name string, prov Provider, named bool, commands []string) error {
+	router.mu.Lock()
+	defer router.mu.Unlock()
+	for _, command := range commands {
+		key := strings.ToLower(command)
+		if existing, ok := router.commands[key]; ok && existing.owner != name {
+			return &ConflictError{Name: key, Owner: existing.owner}
+		}
+		for _, aliases := range router.aliases {
+			if _, ok := aliases[key]; ok {
+				return &ConflictError{Name: key, Owner: "alias"}
+			}
+		}
+	}
+
+	router.unregister(name)
+	if router.commands == nil {
+		router.commands = make(map[string]*route)
+	}
+	for _, command := range commands {
+		router.commands[strings.ToLower(command)] = &route{
+			owner:   name,
+			prov:    prov,
+			command: command,
+			named:   named,
+		}
+	}
+	return nil
+}
+
+// RegisterManifest registers all commands of the manifest of the provider
+// like Register. The provider has to pass the Handshake.
+func (router *Router) RegisterManifest(ctx context.Context, name string, prov Provider) error {
+	manifest, err := Handshake(ctx, prov)
+	if err != nil {
+		return err
+	}
+	commands := make([]string, 0, len(manifest.Commands))
+	for _, info := range manifest.Commands {
+		commands = append(commands, info.Name)
+	}
+	return router.Register(name, prov, commands...)
+}
+
+// Unregister removes all commands of the provider with the name.
+func (router *Router) Unregister(name string) {
+	router.mu.Lock()
+	defer router.mu.Unlock()
+	router.unregister(name)
+}
+
+func (router *Router) unregister(name string) {
+	for key, existing := range router.commands {
+		if existing.owner == name {
+			delete(router.commands, key)
+		}
+	}
+}
+
+// Alias makes alias another name of the command in the channel, or in all
+// channels if the channel is empty. The command doesn't have to be
+// registered yet. Aliases used as command name are refused with a
+// *ConflictError.
+func (router *Router) Alias(channel, alias, command string) error {
+	router.mu.Lock()
+	defer router.mu.Unlock()
+	alias = strings.ToLower(alias)
+	if existing, ok := router.commands[alias]; ok {
+		return &ConflictError{Name: alias, Owner: existing.owner}
+	}
+	if router.aliases == nil {
+		router.aliases = make(map[string]map[string]string)
+	}
+	if router.aliases[channel] == nil {
+		router.aliases[channel] = make(map[string]string)
+	}
+	router.aliases[channel][alias] = strings.ToLower(command)
+	return nil
+}
+
+// SetPrefix sets the prefix of commands in the channel, or of all channels
+// without own prefix if the channel is empty. An empty prefix restores the
+// default.
+func (router *Router) SetPrefix(channel, prefix string) {
+	router.mu.Lock()
+	defer router.mu.Unlock()
+	if prefix == "" {
+		delete(router.prefixes, channel)
+		return
+	}
+	if router.prefixes == nil {
+		router.prefixes = make(map[string]string)
+	}
+	router.prefixes[channel] = prefix
+}
+
+// ResetChannels removes all aliases and channel prefixes, e.g. before
+// applying them again from a reloaded config.
+func (router *Router) ResetChannels() {
+	router.mu.Lock()
+	defer router.mu.Unlock()
+	router.aliases = nil
+	router.prefixes = nil
+}
+
+// Route is the result of resolving a message.
+type Route struct {
+	// Provider is the name of the provider owning the command.
+	Provider string
+	prov     Provider
+	// Command is the name of the command as registered.
+	Command string
+	Args    []string
+	named   bool
+}
+
+// Invoke invokes the command of the route with hub.InvokeRich. The command
+// name is only passed to providers routing by it, see Register.
+func (r *Route) Invoke(ctx context.Context) (*lib.Response, error) {
+	command := ""
+	if r.named {
+		command = r.Command
+	}
+	return InvokeRich(ctx, r.prov, command, r.Args)
+}
+
+// Route resolves the message sent to the channel. Messages without the
+// prefix of the channel fail with ErrNotCommand and unknown commands with
+// a *lib.Error with CodeNotFound.
+func (router *Router) Route(channel, message string) (*Route, error) {
+	router.mu.RLock()
+	defer router.mu.RUnlock()
+
+	prefix := router.prefix(channel)
+	if !strings.HasPrefix(message, prefix) {
+		return nil, ErrNotCommand
+	}
+	args, err := lib.SplitArgs(strings.TrimPrefix(message, prefix))
+	if err != nil {
+		return nil, lib.Errorf(lib.CodeInvalidArgument, "%v", err)
+	}
+	if len(args) == 0 {
+		return nil, ErrNotCommand
+	}
+
+	name := strings.ToLower(args[0])
+	if command, ok := router.aliases[channel][name]; ok {
+		name = command
+	} else if command, ok := router.aliases[""][name]; ok {
+		name = command
+	}
+	target, ok := router.commands[name]
+	if !ok {
+		return nil, router.notFound(channel, prefix, args[0])
+	}
+	return &Route{
+		Provider: target.owner,
+		prov:     target.prov,
+		Command:  target.command,
+		Args:     args[1:],
+		named:    target.named,
+	}, nil
+}
+
+// Handle routes the message like Route and invokes the command. The channel
+// is taken from the invocation carried by ctx.
+func (router *Router) Handle(ctx context.Context, message string) (*lib.Response, error) {
+	channel := ""
+	if inv, ok := lib.InvocationFromContext(ctx); ok {
+		channel = inv.Channel
+	}
+	r, err := router.Route(channel, message)
+	if err != nil {
+		return nil, err
+	}
+	return r.Invoke(ctx)
+}
+
+// prefix returns the prefix of the channel. Must be called with mu held.
+func (router *Router) prefix(channel string) string {
+	if prefix, ok := router.prefixes[channel]; ok {
+		return prefix
+	}
+	if prefix, ok := router.prefixes[""]; ok {
+		return prefix
+	}
+	if router.Prefix == "" {
+		return "!"
+	}
+	return router.Prefix
+}
+
+// notFound returns the error for the unknown command with the most similar
+// name known in the channel. Must be called with mu held.
+func (router *Router) notFound(channel, prefix, command string) error {
+	max := router.MaxDistance
+	if max == 0 {
+		max = 2
+	}
+	var names []string
+	for name := range router.commands {
+		names = append(names, name)
+	}
+	for alias := range router.aliases[""] {
+		names = append(names, alias)
+	}
+	if channel != "" {
+		for alias := range router.aliases[channel] {
+			names = append(names, alias)
+		}
+	}
+	sort.Strings(names)
+
+	suggestion := ""
+	best := max + 1
+	for _, name := range names {
+		if distance := editDistance(strings.ToLower(command), name); distance < best {
+			suggestion, best = prefix+name, distance
+		}
+	}
+
+	if router.NotFound != nil {
+		return lib.Errorf(lib.CodeNotFound, "%s", router.NotFound(prefix+command, suggestion))
+	}
+	if suggestion == "" {
+		return lib.Errorf(lib.CodeNotFound, "unknown command '%s%s'", prefix, command)
+	}
+	return lib.Errorf(lib.CodeNotFound, "unknown command '%s%s', did you mean '%s'?", prefix, command, suggestion)
+}
+
+// editDistance returns the Levenshtein distance of the strings.
+func editDistance(a, b string) int {
+	ra, rb := []rune(a), []rune(b)
+	prev := make([]int, len(rb)+1)
+	cur := make([]int, len(rb)+1)
+	for j := range prev {
+		prev[j] = j
+	}
+	for i := 1; i <= len(ra); i++ {
+		cur[0] = i
+		for j := 1; j <= len(rb); j++ {
+			cost := 1
+			if ra[i-1] == rb[j-1] {
+				cost = 0
+			}
+			cur[j] = min(prev[j]+1, cur[j-1]+1, prev[j-1]+cost)
+		}
+		prev, cur = cur, prev
+	}
+	return prev[len(rb)]
+}
+
+func min(values ...int) int {
+	m := values[0]
+	for _, v := range values[1:] {
+		if v < m {
+			m = v
+		}
+	}
+	return m
+}
@@ -0,0 +1,135 @@
+package hub
+
+import (
+	"context"
+	"strings"
+	"testing"
+
+	"github.com/subcommands_test/cli/lib"
+)
+
+// namedProvider answers every invocation with its name, the command if
+// passed and the arguments.
+type namedProvider string
+
+func (prov namedProvider) Invoke(ctx context.Context, command string, args []string) (string, error) {
+	return strings.Join(append([]string{string(prov)}, withCommand(command, args)...), " "), nil
+}
+
+func (prov namedProvider) Describe(ctx context.Context) (*lib.Manifest, error) {
+	return nil, ErrNoManifest
+}
+
+func (prov namedProvider) Close() error {
+	return nil
+}
+
+func TestRouter(t *testing.T) {
+	router := &Router{}
+	if err := router.Register("cliprov", namedProvider("cliprov"), "hello", "Echo"); err != nil {
+		t.Fatal(err)
+	}
+	if err := router.Register("webprov", namedProvider("webprov"), "roll"); err != nil {
+		t.Fatal(err)
+	}
+	if err := router.RegisterNamed("multi", namedProvider("multi"), "time"); err != nil {
+		t.Fatal(err)
+	}
+	if err := router.Alias("", "hi", "hello"); err != nil {
+		t.Fatal(err)
+	}
+	if err := router.Alias("#dice", "d", "roll"); err != nil {
+		t.Fatal(err)
+	}
+	router.SetPrefix("#dice", "?")
+
+	tests := []struct {
+		channel  string
+		message  string
+		expected string
+	}{
+		{message: `!hello Kevin`, expected: "cliprov hello Kevin"},
+		{message: `!HI "Mary Ann"`, expected: "cliprov hello Mary Ann"},
+		{message: `!echo a b`, expected: "cliprov Echo a b"},
+		{message: `!roll`, expected: "webprov"},
+		{message: `!time UTC`, expected: "multi time UTC"},
+		{channel: "#dice", message: `?d 20`, expected: "webprov 20"},
+		{channel: "#dice", message: `?hi`, expected: "cliprov hello"},
+	}
+	for _, test := range tests {
+		ctx := lib.WithInvocation(context.Background(), &lib.Invocation{Channel: test.channel})
+		resp, err := router.Handle(ctx, test.message)
+		if err != nil {
+			t.Errorf("%s: %v", test.message, err)
+			continue
+		}
+		if resp.String() != test.expected {
+			t.Errorf("%s: invalid result '%s', expected '%s'", test.message, resp.String(), test.expected)
+		}
+	}
+
+	for _, message := range []string{"hello", "!", "?d 20"} {
+		if _, err := router.Route("", message); err != ErrNotCommand {
+			t.Errorf("%s: expected ErrNotCommand, got %v", message, err)
+		}
+	}
+
+	router.Unregister("webprov")
+	if _, err := router.Route("#dice", "?roll"); lib.AsError(err).Code != lib.CodeNotFound {
+		t.Errorf("expected not found after unregister, got %v", err)
+	}
+}
+
+func TestRouter_Conflict(t *testing.T) {
+	router := &Router{}
+	if err := router.Register("cliprov", namedProvider("cliprov"), "hello"); err != nil {
+		t.Fatal(err)
+	}
+	err := router.Register("webprov", namedProvider("webprov"), "echo", "HELLO")
+	if conflict, ok := err.(*ConflictError); !ok || conflict.Name != "hello" || conflict.Owner != "cliprov" {
+		t.Errorf("expected conflict with cliprov, got %v", err)
+	}
+	if _, err := router.Route("", "!echo"); err == nil {
+		t.Error("commands of conflicting registration were registered")
+	}
+	if err := router.Alias("", "Hello", "echo"); err == nil {
+		t.Error("expected alias using command name to conflict")
+	}
+	// Registering again replaces the commands of the provider
+	if err := router.Register("cliprov", namedProvider("cliprov"), "hey"); err != nil {
+		t.Fatal(err)
+	}
+	if err := router.Register("webprov", namedProvider("webprov"), "hello"); err != nil {
+		t.Errorf("command of replaced registration still conflicts: %v", err)
+	}
+}
+
+func TestRouter_Suggestion(t *testing.T) {
+	router := &Router{}
+	router.Register("cliprov", namedProvider("cliprov"), "hello", "echo")
+	router.Alias("", "hi", "hello")
+
+	tests := []struct {
+		message  string
+		expected string
+	}{
+		{message: "!helo", expected: "unknown command '!helo', did you mean '!hello'?"},
+		{message: "!ech", expected: "unknown command '!ech', did you mean '!echo'?"},
+		{message: "!ho", expected: "unknown command '!ho', did you mean '!hi'?"},
+		{message: "!roll", expected: "unknown command '!roll'"},
+	}
+	for _, test := range tests {
+		_, err := router.Route("", test.message)
+		if e := lib.AsError(err); e.Code != lib.CodeNotFound || e.Message != test.expected {
+			t.Errorf("%s: invalid error %v", test.message, err)
+		}
+	}
+
+	router.MaxDistance = -1
+	router.NotFound = func(command, suggestion string) string {
+		return "no " + command + suggestion
+	}
+	if _, err := router.Route("", "!helo"); lib.AsError(err).Message != "no !helo" {
+		t.Errorf("invalid error without suggestions %v", err)
+	}
+}
@@ -0,0 +1,107 @@
+package hub
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/subcommands_test/cli/lib"
+)
+
+// ErrTimeout is returned for invocations exceeding the timeout enforced by
+// the hub. The provider is told to abandon them if the transport allows it.
+var ErrTimeout = errors.New("invocation timed out")
+
+// Timeouts limits how long invocations may take.
+type Timeouts struct {
+	// Default is the timeout of commands without own timeout. Zero doesn't
+	// limit them.
+	Default time.Duration
+	// Commands maps command names to their timeout. Names are matched
+	// case-insensitive.
+	Commands map[string]time.Duration
+}
+
+// For returns the timeout of the command. Invocations without command name
+// are looked up by their first argument, which names the command then.
+func (timeouts Timeouts) For(command string, args []string) time.Duration {
+	if command == "" && len(args) > 0 {
+		command = args[0]
+	}
+	for name, timeout := range timeouts.Commands {
+		if strings.EqualFold(name, command) {
+			return timeout
+		}
+	}
+	return timeouts.Default
+}
+
+// WithTimeouts returns a StreamProvider invoking prov with the timeouts.
+// Invocations exceeding them fail with ErrTimeout, while invocations ended
+// by the caller's ctx still fail with its error.
+func WithTimeouts(prov Provider, timeouts Timeouts) StreamProvider {
+	return &timeoutProvider{
+		Provider: prov,
+		timeouts: timeouts,
+	}
+}
+
+type timeoutProvider struct {
+	Provider
+	timeouts Timeouts
+}
+
+func (prov *timeoutProvider) Invoke(ctx context.Context, command string, args []string) (result string, err error) {
+	err = prov.invoke(ctx, command, args, func(ctx context.Context) error {
+		result, err = prov.Provider.Invoke(ctx, command, args)
+		return err
+	})
+	return result, err
+}
+
+func (prov *timeoutProvider) InvokeRich(ctx context.Context, command string, args []string) (resp *lib.Response, err error) {
+	err = prov.invoke(ctx, command, args, func(ctx context.Context) error {
+		resp, err = InvokeRich(ctx, prov.Provider, command, args)
+		return err
+	})
+	return resp, err
+}
+
+func (prov *timeoutProvider) InvokeStream(ctx context.Context, command string, args []string, chunks func(*lib.Response)) (resp *lib.Response, err error) {
+	err = prov.invoke(ctx, command, args, func(ctx context.Context) error {
+		resp, err = InvokeStream(ctx, prov.Provider, command, args, chunks)
+		return err
+	})
+	return resp, err
+}
+
+// invoke calls fn with the timeout of the command applied to ctx.
+func (prov *timeoutProvider) invoke(ctx context.Context, command string, args []string, fn func(context.Context) error) error {
+	timeout := prov.timeouts.For(command, args)
+	if timeout <= 0 {
+		return fn(ctx)
+	}
+	timeoutCtx, cancel := context.WithTimeout(ctx, timeout)
+	defer cancel()
+	err := fn(timeoutCtx)
+	if err != nil && ctx.Err() == nil && prov.expired(ctx, timeoutCtx, err) {
+		if command == "" && len(args) > 0 {
+			command = args[0]
+		}
+		return fmt.Errorf("%w: '%s' took longer than %s", ErrTimeout, command, timeout)
+	}
+	return err
+}
+
+// expired reports whether the invocation failed because the deadline of
+// timeoutCtx passed, which isn't the one of ctx. Providers may notice the
+// deadline before timeoutCtx does.
+func (prov *timeoutProvider) expired(ctx, timeoutCtx context.Context, err error) bool {
+	deadline, _ := timeoutCtx.Deadline()
+	if parent, ok := ctx.Deadline(); ok && parent.Equal(deadline) {
+		return false
+	}
+	return timeoutCtx.Err() == context.DeadlineExceeded || errors.Is(err, context.DeadlineExceeded)
+}
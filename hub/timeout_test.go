@@ -0,0 +1,188 @@
+package hub
+
+import (
+	"context"
+	"errors"
+	"io"
+	"testing"
+	"time"
+
+	"github.com/subcommands_test/cli/lib"
+	"github.com/subcommands_test/grpc/pb"
+	"github.com/subcommands_test/grpc/provider"
+	"google.golang.org/grpc"
+)
+
+// slowRegistry greets immediately, but takes a second to answer 'slow'
+// unless the invocation is canceled.
+func slowRegistry(canceled chan<- struct{}) *lib.Registry {
+	reg := lib.NewRegistry()
+	reg.Fallback = lib.ContextCommandFunc(func(ctx context.Context, args []string) (string, error) {
+		if len(args) > 0 && args[0] == "slow" {
+			select {
+			case <-time.After(time.Second):
+				return "finally", nil
+			case <-ctx.Done():
+				if canceled != nil {
+					canceled <- struct{}{}
+				}
+				return "", ctx.Err()
+			}
+		}
+		return lib.HelloProvider(args), nil
+	})
+	return reg
+}
+
+func testTimeout(t *testing.T, prov Provider) {
+	timed := WithTimeouts(prov, Timeouts{
+		Default:  time.Second,
+		Commands: map[string]time.Duration{"SLOW": 50 * time.Millisecond},
+	})
+	started := time.Now()
+	_, err := timed.Invoke(context.Background(), "", []string{"slow"})
+	if !errors.Is(err, ErrTimeout) {
+		t.Errorf("expected ErrTimeout, got %v", err)
+	}
+	if elapsed := time.Since(started); elapsed > 500*time.Millisecond {
+		t.Errorf("stuck invocation returned after %s", elapsed)
+	}
+
+	// The late result of the abandoned invocation must not be mixed up
+	testInvoke(t, timed)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	if _, err := timed.Invoke(ctx, "", []string{"Kevin"}); err == nil || errors.Is(err, ErrTimeout) {
+		t.Errorf("expected canceled invocation to fail without ErrTimeout, got %v", err)
+	}
+}
+
+func TestTimeouts(t *testing.T) {
+	t.Run("cli", func(t *testing.T) {
+		inReader, inWriter := io.Pipe()
+		outReader, outWriter := io.Pipe()
+		provider := &lib.ReaderWriterProvider{
+			Input:          inReader,
+			Output:         outWriter,
+			ContextHandler: slowRegistry(nil),
+		}
+		provider.Start()
+		prov := NewCliProvider(inWriter, outReader)
+		defer prov.Close()
+		testTimeout(t, prov)
+	})
+	t.Run("framed", func(t *testing.T) {
+		canceled := make(chan struct{}, 1)
+		inReader, inWriter := io.Pipe()
+		outReader, outWriter := io.Pipe()
+		provider := &lib.ReaderWriterProvider{
+			Input:          inReader,
+			Output:         outWriter,
+			Framed:         true,
+			MaxInFlight:    2,
+			ContextHandler: slowRegistry(canceled),
+		}
+		provider.Start()
+		prov := NewFramedCliProvider(inWriter, outReader)
+		defer prov.Close()
+		testTimeout(t, prov)
+		select {
+		case <-canceled:
+		case <-time.After(time.Second):
+			t.Error("provider wasn't told to cancel")
+		}
+	})
+	t.Run("grpc", func(t *testing.T) {
+		canceled := make(chan struct{}, 1)
+		srv := grpc.NewServer()
+		pb.RegisterCommandServer(srv, &provider.CommandProviderServer{RichHandler: slowRegistry(canceled)})
+		defer srv.Stop()
+		prov := NewGrpcProvider(dialBufconn(t, srv))
+		defer prov.Close()
+		testTimeout(t, prov)
+		select {
+		case <-canceled:
+		case <-time.After(time.Second):
+			t.Error("provider wasn't told to cancel")
+		}
+	})
+	t.Run("grpc-stream", func(t *testing.T) {
+		canceled := make(chan struct{}, 1)
+		srv := grpc.NewServer()
+		pb.RegisterCommandServer(srv, &provider.CommandProviderServer{RichHandler: slowRegistry(canceled)})
+		defer srv.Stop()
+		prov, err := NewGrpcStreamProvider(context.Background(), dialBufconn(t, srv))
+		if err != nil {
+			t.Fatal(err)
+		}
+		defer prov.Close()
+		testTimeout(t, prov)
+		select {
+		case <-canceled:
+		case <-time.After(time.Second):
+			t.Error("replaced stream wasn't canceled")
+		}
+	})
+}
+
+func TestTimeouts_Stuck(t *testing.T) {
+	release := make(chan struct{})
+	defer close(release)
+	reg := lib.NewRegistry()
+	// Ignores the canceled context like a stuck command
+	reg.Fallback = lib.ContextCommandFunc(func(ctx context.Context, args []string) (string, error) {
+		if len(args) > 0 && args[0] == "stuck" {
+			<-release
+		}
+		return lib.HelloProvider(args), nil
+	})
+	srv := grpc.NewServer()
+	pb.RegisterCommandServer(srv, &provider.CommandProviderServer{RichHandler: reg})
+	defer srv.Stop()
+	prov, err := NewGrpcStreamProvider(context.Background(), dialBufconn(t, srv))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer prov.Close()
+	timed := WithTimeouts(prov, Timeouts{
+		Default:  time.Second,
+		Commands: map[string]time.Duration{"stuck": 100 * time.Millisecond},
+	})
+
+	queued := make(chan error, 1)
+	go func() {
+		time.Sleep(20 * time.Millisecond)
+		_, err := prov.Invoke(context.Background(), "", []string{"Kevin"})
+		queued <- err
+	}()
+	if _, err := timed.Invoke(context.Background(), "", []string{"stuck"}); !errors.Is(err, ErrTimeout) {
+		t.Errorf("expected ErrTimeout, got %v", err)
+	}
+	select {
+	case err := <-queued:
+		if lib.AsError(err).Code != lib.CodeUnavailable {
+			t.Errorf("expected invocation queued behind the stuck one to fail with CodeUnavailable, got %v", err)
+		}
+	case <-time.After(time.Second):
+		t.Fatal("invocation queued behind the stuck one is stuck too")
+	}
+	// Following invocations use a new stream
+	testInvoke(t, timed)
+}
+
+func TestTimeouts_For(t *testing.T) {
+	timeouts := Timeouts{
+		Default:  time.Second,
+		Commands: map[string]time.Duration{"roll": time.Minute},
+	}
+	if timeout := timeouts.For("Roll", nil); timeout != time.Minute {
+		t.Errorf("invalid timeout of command %s", timeout)
+	}
+	if timeout := timeouts.For("", []string{"roll", "20"}); timeout != time.Minute {
+		t.Errorf("invalid timeout of command given as argument %s", timeout)
+	}
+	if timeout := timeouts.For("hello", nil); timeout != time.Second {
+		t.Errorf("invalid default timeout %s", timeout)
+	}
+}
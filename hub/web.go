@@ -0,0 +1,193 @@
+package hub
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"net/url"
+	"strings"
+
+	"github.com/subcommands_test/cli/lib"
+)
+
+// WebProvider invokes commands by sending the arguments as 'params' query
+// value to the http endpoint of a web provider. Arguments are quoted by
+// lib.JoinArgs if necessary.
+type WebProvider struct {
+	URL    string
+	Client *http.Client
+}
+
+// NewWebProvider creates a WebProvider for the given endpoint using its own
+// http client with the default WebOptions. Endpoints like
+// 'unix:///tmp/web_subcommand.sock' are dialed as unix socket.
+func NewWebProvider(endpoint string) *WebProvider {
+	endpoint, socket := SplitWebAddress(endpoint)
+	return &WebProvider{
+		URL:    endpoint,
+		Client: NewWebClient(WebOptions{Socket: socket}),
+	}
+}
+
+// Invoke sends a GET request to the provider and returns the response body.
+// The command name is appended to the path of the URL.
+// Responses with a non-2xx status are returned as *lib.Error with the body as message.
+func (prov *WebProvider) Invoke(ctx context.Context, command string, args []string) (string, error) {
+	resp, err := prov.get(ctx, command, args, "text/plain")
+	if err != nil {
+		return "", err
+	}
+	defer closeBody(resp.Body)
+
+	body, err := ioutil.ReadAll(resp.Body)
+	if err != nil {
+		return "", err
+	}
+	return strings.Trim(string(body), " \n"), nil
+}
+
+// InvokeRich calls InvokeStream and returns the messages of all chunks
+// together with the response.
+func (prov *WebProvider) InvokeRich(ctx context.Context, command string, args []string) (*lib.Response, error) {
+	return collect(ctx, prov, command, args)
+}
+
+// InvokeStream sends a GET request like Invoke but asks for the structured
+// response. Providers streaming the response send frames, whose chunks are
+// passed to chunks as they arrive. Providers answering with plain text are
+// mapped to a single text message.
+func (prov *WebProvider) InvokeStream(ctx context.Context, command string, args []string, chunks func(*lib.Response)) (*lib.Response, error) {
+	resp, err := prov.get(ctx, command, args, "application/x-ndjson, application/json")
+	if err != nil {
+		return nil, err
+	}
+	defer closeBody(resp.Body)
+
+	contentType := resp.Header.Get("Content-Type")
+	switch {
+	case strings.HasPrefix(contentType, "application/x-ndjson"):
+		reader := bufio.NewReader(resp.Body)
+		for {
+			line, err := reader.ReadBytes('\n')
+			if err == io.EOF {
+				return nil, io.ErrUnexpectedEOF
+			}
+			if err != nil {
+				return nil, err
+			}
+			frame, err := lib.ParseFrame(line)
+			if err != nil {
+				return nil, err
+			}
+			switch {
+			case frame.Type == lib.FrameChunk:
+				chunks(frameResponse(frame))
+			case frame.Error != nil:
+				return nil, frame.Error
+			default:
+				return frameResponse(frame), nil
+			}
+		}
+	case strings.HasPrefix(contentType, "application/json"):
+		var response lib.Response
+		err = json.NewDecoder(resp.Body).Decode(&response)
+		if err != nil {
+			return nil, err
+		}
+		return &response, nil
+	default:
+		body, err := ioutil.ReadAll(resp.Body)
+		if err != nil {
+			return nil, err
+		}
+		return lib.Text(strings.Trim(string(body), " \n")), nil
+	}
+}
+
+// get sends the invocation accepting the given content type. The invocation
+// of ctx is sent as headers. Responses with a non-2xx status are returned as
+// *lib.Error, decoded from JSON error bodies like '{"error":{...}}' if sent.
+func (prov *WebProvider) get(ctx context.Context, command string, args []string, accept string) (*http.Response, error) {
+	endpoint, err := url.Parse(prov.URL)
+	if err != nil {
+		return nil, err
+	}
+	if command != "" {
+		endpoint.Path = strings.TrimSuffix(endpoint.Path, "/") + "/" + command
+	}
+	query := endpoint.Query()
+	query.Set("params", lib.JoinArgs(args))
+	endpoint.RawQuery = query.Encode()
+
+	req, err := http.NewRequest(http.MethodGet, endpoint.String(), nil)
+	if err != nil {
+		return nil, err
+	}
+	req.Header.Set("Accept", accept)
+	if inv, ok := lib.InvocationFromContext(ctx); ok {
+		inv.WriteHeader(req.Header)
+	}
+	resp, err := prov.Client.Do(req.WithContext(ctx))
+	if err != nil {
+		return nil, err
+	}
+	if resp.StatusCode < 200 || resp.StatusCode >= 300 {
+		defer closeBody(resp.Body)
+		body, err := ioutil.ReadAll(resp.Body)
+		if err != nil {
+			return nil, err
+		}
+		if strings.HasPrefix(resp.Header.Get("Content-Type"), "application/json") {
+			var errBody struct {
+				Error *lib.Error `json:"error"`
+			}
+			if json.Unmarshal(body, &errBody) == nil && errBody.Error != nil {
+				return nil, errBody.Error
+			}
+		}
+		return nil, &lib.Error{
+			Code:    lib.CodeFromHTTPStatus(resp.StatusCode),
+			Message: strings.Trim(string(body), " \n"),
+		}
+	}
+	return resp, nil
+}
+
+// Describe gets the manifest from the '/manifest' endpoint of the provider.
+func (prov *WebProvider) Describe(ctx context.Context) (*lib.Manifest, error) {
+	endpoint, err := url.Parse(prov.URL)
+	if err != nil {
+		return nil, err
+	}
+	endpoint.Path = strings.TrimSuffix(endpoint.Path, "/") + "/manifest"
+
+	req, err := http.NewRequest(http.MethodGet, endpoint.String(), nil)
+	if err != nil {
+		return nil, err
+	}
+	resp, err := prov.Client.Do(req.WithContext(ctx))
+	if err != nil {
+		return nil, err
+	}
+	defer closeBody(resp.Body)
+
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("%w: unexpected status %s", ErrNoManifest, resp.Status)
+	}
+	var manifest lib.Manifest
+	err = json.NewDecoder(resp.Body).Decode(&manifest)
+	if err != nil {
+		return nil, err
+	}
+	return &manifest, nil
+}
+
+// Close closes idle connections of the client.
+func (prov *WebProvider) Close() error {
+	prov.Client.CloseIdleConnections()
+	return nil
+}
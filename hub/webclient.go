@@ -0,0 +1,107 @@
+package hub
+
+import (
+	"context"
+	"crypto/tls"
+	"io"
+	"io/ioutil"
+	"net"
+	"net/http"
+	"strings"
+	"time"
+
+	"golang.org/x/net/http2"
+)
+
+// maxDrainBytes limits how much of an unread body is drained, so the
+// connection can be reused. Larger bodies are dropped with the connection.
+const maxDrainBytes = 64 << 10
+
+// WebOptions configure the http client of a WebProvider. Every provider
+// gets its own transport, so the limits apply per provider.
+type WebOptions struct {
+	// H2C sends requests with cleartext HTTP/2 instead of HTTP/1.1, so all
+	// invocations share a single connection. The provider has to accept
+	// h2c with prior knowledge.
+	H2C bool
+	// Socket is the path of a unix socket dialed instead of the host of
+	// the URL.
+	Socket string
+	// MaxConns limits the HTTP/1.1 connections to the provider, further
+	// requests wait for a free one. Zero doesn't limit them.
+	MaxConns int
+	// MaxIdleConns is the number of idle keep-alive connections kept for
+	// the next requests. Defaults to 16.
+	MaxIdleConns int
+	// IdleTimeout closes keep-alive connections idle for longer. Defaults
+	// to 90s.
+	IdleTimeout time.Duration
+	// DialTimeout limits connecting to the provider. Defaults to 5s.
+	DialTimeout time.Duration
+}
+
+// SplitWebAddress splits addresses of web providers listening on a unix
+// socket like 'unix:///tmp/web_subcommand.sock' into the URL requested and
+// the path of the socket dialed. Other addresses are returned as URL.
+func SplitWebAddress(address string) (endpoint, socket string) {
+	if strings.HasPrefix(address, "unix://") {
+		return "http://localhost", strings.TrimPrefix(address, "unix://")
+	}
+	return address, ""
+}
+
+func (opts *WebOptions) defaults() {
+	if opts.MaxIdleConns <= 0 {
+		opts.MaxIdleConns = 16
+	}
+	if opts.IdleTimeout <= 0 {
+		opts.IdleTimeout = 90 * time.Second
+	}
+	if opts.DialTimeout <= 0 {
+		opts.DialTimeout = 5 * time.Second
+	}
+}
+
+// NewWebClient creates an http client with a dedicated transport
+// configured by opts. Unlike http.DefaultClient it keeps enough idle
+// connections to reuse one for every invocation in flight.
+func NewWebClient(opts WebOptions) *http.Client {
+	opts.defaults()
+	dialer := &net.Dialer{
+		Timeout:   opts.DialTimeout,
+		KeepAlive: 30 * time.Second,
+	}
+	dial := func(ctx context.Context, network, addr string) (net.Conn, error) {
+		if opts.Socket != "" {
+			return dialer.DialContext(ctx, "unix", opts.Socket)
+		}
+		return dialer.DialContext(ctx, network, addr)
+	}
+	if opts.H2C {
+		return &http.Client{
+			Transport: &http2.Transport{
+				AllowHTTP: true,
+				// Dials without TLS, the name is required by AllowHTTP
+				DialTLS: func(network, addr string, cfg *tls.Config) (net.Conn, error) {
+					return dial(context.Background(), network, addr)
+				},
+			},
+		}
+	}
+	return &http.Client{
+		Transport: &http.Transport{
+			DialContext:         dial,
+			MaxConnsPerHost:     opts.MaxConns,
+			MaxIdleConns:        opts.MaxIdleConns,
+			MaxIdleConnsPerHost: opts.MaxIdleConns,
+			IdleConnTimeout:     opts.IdleTimeout,
+		},
+	}
+}
+
+// closeBody drains what's left of the body and closes it, which lets the
+// transport reuse the connection.
+func closeBody(body io.ReadCloser) error {
+	io.Copy(ioutil.Discard, io.LimitReader(body, maxDrainBytes))
+	return body.Close()
+}
@@ -0,0 +1,61 @@
+package hub
+
+import (
+	"context"
+	"fmt"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"sync/atomic"
+	"testing"
+
+	"golang.org/x/net/http2"
+	"golang.org/x/net/http2/h2c"
+)
+
+func TestWebClient_KeepAlive(t *testing.T) {
+	var conns int32
+	srv := httptest.NewUnstartedServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		// The body of failed requests for the manifest isn't read, but
+		// has to be drained
+		http.Error(w, "not found", http.StatusNotFound)
+	}))
+	srv.Config.ConnState = func(conn net.Conn, state http.ConnState) {
+		if state == http.StateNew {
+			atomic.AddInt32(&conns, 1)
+		}
+	}
+	srv.Start()
+	defer srv.Close()
+
+	prov := NewWebProvider(srv.URL)
+	defer prov.Close()
+	for i := 0; i < 10; i++ {
+		if _, err := prov.Describe(context.Background()); err == nil {
+			t.Fatal("expected error")
+		}
+	}
+	if n := atomic.LoadInt32(&conns); n != 1 {
+		t.Errorf("expected a single connection to be reused, got %d", n)
+	}
+}
+
+func TestWebClient_H2C(t *testing.T) {
+	srv := httptest.NewServer(h2c.NewHandler(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		fmt.Fprintf(w, "HTTP/%d", r.ProtoMajor)
+	}), &http2.Server{}))
+	defer srv.Close()
+
+	prov := &WebProvider{
+		URL:    srv.URL,
+		Client: NewWebClient(WebOptions{H2C: true}),
+	}
+	defer prov.Close()
+	result, err := prov.Invoke(context.Background(), "hello", nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if result != "HTTP/2" {
+		t.Errorf("expected HTTP/2, got '%s'", result)
+	}
+}
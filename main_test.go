@@ -8,195 +8,166 @@ import (
 	"io"
 	"io/ioutil"
 	"net/http"
-	"os"
-	"os/exec"
 	"strings"
 	"testing"
 	"time"
 
+	"github.com/subcommands_test/config"
 	"github.com/subcommands_test/grpc/pb"
+	"github.com/subcommands_test/hub"
+	"github.com/subcommands_test/supervisor"
 	"google.golang.org/grpc"
 )
 
 type testHandler func(in io.WriteCloser, reader *bufio.Reader, errOut *bytes.Buffer)
 
-func testStart(t *testing.T, command []string, iteration testHandler) {
-	cmd := exec.Command(command[0], command[1:]...)
-	var errOut bytes.Buffer
-	cmd.Stderr = &errOut
-	in, err := cmd.StdinPipe()
+// configPath is the config file listing the providers used by the tests.
+const configPath = "providers.yaml"
+
+// start launches the provider with the name from the config file with a
+// supervisor and waits until it announced it's ready. in and out are
+// connected to the stdin and stdout of the process.
+func start(tb testing.TB, name string) (sup *supervisor.Supervisor, in io.WriteCloser, out *bufio.Reader, errOut *bytes.Buffer) {
+	cfg, err := config.Load(configPath)
 	if err != nil {
-		t.Fatal(err)
+		tb.Fatal(err)
 	}
-	reader, err := cmd.StdoutPipe()
-	if err != nil {
-		t.Fatal(err)
+	prov, ok := cfg.Provider(name)
+	if !ok {
+		tb.Fatalf("provider '%s' isn't configured in %s", name, configPath)
 	}
-	buff := bufio.NewReader(reader)
-
-	waitc := make(chan struct{})
-	go func() {
-		defer close(waitc)
-		err = cmd.Run()
-		if err != nil {
-			t.Log(err, errOut.String())
-		}
-	}()
+	errOut = &bytes.Buffer{}
+	sup = prov.Supervisor(errOut)
+	sup.Connect = func(stdin io.WriteCloser, stdout io.ReadCloser) error {
+		in = stdin
+		out = bufio.NewReader(stdout)
+		return nil
+	}
+	if err := sup.Start(); err != nil {
+		tb.Fatal(err)
+	}
+	return sup, in, out, errOut
+}
 
-	<-time.After(100 * time.Millisecond)
+func testStart(t *testing.T, name string, iteration testHandler) {
+	sup, in, out, errOut := start(t, name)
+	defer sup.Stop()
 
-	iteration(in, buff, &errOut)
+	iteration(in, out, errOut)
+}
 
-	select {
-	case <-waitc:
-	case <-time.After(time.Second):
-		err = cmd.Process.Kill()
-		if err != nil {
-			t.Fatal(err)
-		}
-		select {
-		case <-waitc:
-		case <-time.After(time.Second):
-			t.Fatal("failed to kill process")
-		}
+func testProvider(t *testing.T, prov hub.Provider) {
+	defer prov.Close()
+	timed := hub.WithTimeouts(prov, hub.Timeouts{Default: time.Second})
+	response, err := timed.Invoke(context.Background(), "", []string{"Kevin"})
+	if err != nil {
+		t.Error(err)
+		return
+	}
+	if response != "Hello, Kevin!" {
+		t.Errorf("invalid response: '%s'", response)
 	}
 }
 
 func TestCli(t *testing.T) {
-	testStart(t, []string{"build/cliprov"}, func(in io.WriteCloser, out *bufio.Reader, errOut *bytes.Buffer) {
-		_, err := fmt.Fprintln(in, "Kevin")
-		if err != nil {
-			t.Error(err, errOut.String())
-			return
-		}
-		response, err := out.ReadString('\n')
+	testStart(t, "cliprov", func(in io.WriteCloser, out *bufio.Reader, errOut *bytes.Buffer) {
+		testProvider(t, hub.NewCliProvider(in, out))
+	})
+}
+
+func TestCliFramed(t *testing.T) {
+	testStart(t, "cliprov-framed", func(in io.WriteCloser, out *bufio.Reader, errOut *bytes.Buffer) {
+		prov := hub.NewFramedCliProvider(in, out)
+		manifest, err := hub.Handshake(context.Background(), prov)
 		if err != nil {
 			t.Error(err)
-			return
-		}
-		response = strings.TrimSpace(response)
-		if response != "Hello, Kevin!" {
-			t.Errorf("Invalid output '%s' - %s", response, errOut.String())
+		} else if manifest.Name != "cliprov" {
+			t.Errorf("invalid manifest: %+v", *manifest)
 		}
+		testProvider(t, prov)
 	})
 }
 
 func TestWeb(t *testing.T) {
-	testStart(t, []string{"build/webprov"}, func(in io.WriteCloser, out *bufio.Reader, errOut *bytes.Buffer) {
-		var err error
-		var resp *http.Response
-		for i := 0; i < 5; i++ {
-			<-time.After(250 * time.Millisecond)
-			resp, err = http.Get("http://localhost:8080?params=Kevin")
-			if err == nil {
-				break
-			}
-		}
-		if err != nil {
+	testStart(t, "webprov", func(in io.WriteCloser, out *bufio.Reader, errOut *bytes.Buffer) {
+		prov := hub.NewWebProvider("http://localhost:8080")
+		if err := prov.Health(context.Background()); err != nil {
 			t.Error(err)
-			return
 		}
-		respBody, err := ioutil.ReadAll(resp.Body)
+		testProvider(t, prov)
+	})
+}
+
+func TestWebMulti(t *testing.T) {
+	testStart(t, "webprov-multi", func(in io.WriteCloser, out *bufio.Reader, errOut *bytes.Buffer) {
+		prov := hub.NewWebProvider("http://localhost:8081")
+		defer prov.Close()
+		response, err := prov.Invoke(context.Background(), "echo", []string{"Mary Ann", "Kevin"})
 		if err != nil {
 			t.Error(err)
 			return
 		}
-		response := strings.Trim(string(respBody), " \n")
-		if response != "Hello, Kevin!" {
+		if response != "Mary Ann Kevin" {
 			t.Errorf("invalid response: '%s'", response)
 		}
+		testProvider(t, prov)
 	})
 }
 
-func TestGrpc(t *testing.T) {
-	testStart(t, []string{"build/grpcprov"}, func(in io.WriteCloser, out *bufio.Reader, errOut *bytes.Buffer) {
-		var conn *grpc.ClientConn
-		var err error
-		for i := 0; i < 5; i++ {
-			<-time.After(250 * time.Millisecond)
-			conn, err = grpc.Dial("unix:///tmp/grpc_subcommand.sock", grpc.WithInsecure())
-			if err == nil {
-				break
-			}
+func TestWebH2C(t *testing.T) {
+	testStart(t, "webprov-h2c", func(in io.WriteCloser, out *bufio.Reader, errOut *bytes.Buffer) {
+		prov := &hub.WebProvider{
+			URL:    "http://localhost:8082",
+			Client: hub.NewWebClient(hub.WebOptions{H2C: true}),
 		}
-		if err != nil {
-			t.Error(err, errOut.String())
-			return
-		}
-		defer conn.Close()
-		client := pb.NewCommandClient(conn)
+		testProvider(t, prov)
+	})
+}
 
-		resp, err := client.Handle(context.Background(), &pb.CommandArguments{
-			Args: []string{"Kevin"},
-		})
+func TestWebSocket(t *testing.T) {
+	testStart(t, "webprov-socket", func(in io.WriteCloser, out *bufio.Reader, errOut *bytes.Buffer) {
+		testProvider(t, hub.NewWebProvider("unix:///tmp/web_subcommand.sock"))
+	})
+}
+
+func TestGrpc(t *testing.T) {
+	testStart(t, "grpcprov", func(in io.WriteCloser, out *bufio.Reader, errOut *bytes.Buffer) {
+		conn, err := grpc.Dial("unix:///tmp/grpcprov.sock", grpc.WithInsecure())
 		if err != nil {
 			t.Error(err, errOut.String())
 			return
 		}
-		if resp.Result != "Hello, Kevin!" {
-			t.Errorf("invalid result: %s", resp.Result)
+		unary := hub.NewGrpcProvider(conn)
+		if err := unary.Health(context.Background()); err != nil {
+			t.Error(err, errOut.String())
 		}
+		testProvider(t, unary)
 
-		stream, err := client.HandleStream(context.Background())
+		conn, err = grpc.Dial("unix:///tmp/grpcprov.sock", grpc.WithInsecure())
 		if err != nil {
 			t.Error(err, errOut.String())
 			return
 		}
-		err = stream.Send(&pb.CommandArguments{
-			Args: []string{"Kevin"},
-		})
+		prov, err := hub.NewGrpcStreamProvider(context.Background(), conn)
 		if err != nil {
 			t.Error(err, errOut.String())
 			return
 		}
-		if resp.Result != "Hello, Kevin!" {
-			t.Errorf("invalid result: %s", resp.Result)
-		}
+		testProvider(t, prov)
 	})
-	os.Remove("/tmp/grpc_subcommand.sock")
 }
 
-func benchStart(b *testing.B, command []string, iteration testHandler) {
-	cmd := exec.Command(command[0], command[1:]...)
-	var errOut bytes.Buffer
-	cmd.Stderr = &errOut
-	in, err := cmd.StdinPipe()
-	if err != nil {
-		b.Fatal(err)
-	}
-	reader, err := cmd.StdoutPipe()
-	if err != nil {
-		b.Fatal(err)
-	}
-	buff := bufio.NewReader(reader)
-
-	waitc := make(chan struct{})
-	go func() {
-		defer close(waitc)
-		err = cmd.Run()
-		if err != nil {
-			b.Log(err, errOut.String())
-		}
-	}()
-
-	<-time.After(100 * time.Millisecond)
+func benchStart(b *testing.B, name string, iteration testHandler) {
+	sup, in, out, errOut := start(b, name)
+	defer sup.Stop()
 
 	b.ResetTimer()
-	iteration(in, buff, &errOut)
+	iteration(in, out, errOut)
 	b.StopTimer()
-
-	select {
-	case <-waitc:
-	case <-time.After(time.Second):
-		err = cmd.Process.Kill()
-		if err != nil {
-			b.Fatal(err)
-		}
-	}
 }
 
 func BenchmarkCli(b *testing.B) {
-	benchStart(b, []string{"build/cliprov"}, func(in io.WriteCloser, out *bufio.Reader, errOut *bytes.Buffer) {
+	benchStart(b, "cliprov", func(in io.WriteCloser, out *bufio.Reader, errOut *bytes.Buffer) {
 		waitc := make(chan struct{})
 		go func() {
 			defer close(waitc)
@@ -230,50 +201,75 @@ func BenchmarkCli(b *testing.B) {
 	})
 }
 
-func BenchmarkWeb(b *testing.B) {
-	benchStart(b, []string{"build/webprov"}, func(in io.WriteCloser, out *bufio.Reader, errOut *bytes.Buffer) {
-		// Wait till subcommand is ready
-		var err error
-		for i := 0; i < 5; i++ {
-			<-time.After(250 * time.Millisecond)
-			_, err = http.Get("http://localhost:8080?params=Kevin")
-			if err == nil {
-				break
-			}
-		}
+func BenchmarkCli_Framed(b *testing.B) {
+	benchStart(b, "cliprov-framed", func(in io.WriteCloser, out *bufio.Reader, errOut *bytes.Buffer) {
+		prov := hub.NewFramedCliProvider(in, out)
+		defer prov.Close()
 
 		b.ResetTimer()
-		for i := 0; i < b.N; i++ {
-			resp, err := http.Get("http://localhost:8080?params=Kevin")
-			if err != nil {
-				b.Error(err)
-				return
-			}
-			respBody, err := ioutil.ReadAll(resp.Body)
-			if err != nil {
-				b.Error(err)
-				return
-			}
-			response := strings.Trim(string(respBody), " \n")
-			if response != "Hello, Kevin!" {
-				b.Errorf("invalid response: '%s'", response)
-				return
+		b.RunParallel(func(p *testing.PB) {
+			for p.Next() {
+				response, err := prov.Invoke(context.Background(), "", []string{"Kevin"})
+				if err != nil {
+					b.Error(err, errOut.String())
+					return
+				}
+				if response != "Hello, Kevin!" {
+					b.Errorf("Invalid output '%s' - %s", response, errOut.String())
+					return
+				}
 			}
+		})
+		b.StopTimer()
+	})
+}
+
+// benchWeb invokes hello at the endpoint with the client, which reuses its
+// connections as the bodies are read and closed.
+func benchWeb(b *testing.B, endpoint string, client *http.Client) {
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		resp, err := client.Get(endpoint + "?params=Kevin")
+		if err != nil {
+			b.Error(err)
+			return
 		}
+		respBody, err := ioutil.ReadAll(resp.Body)
+		resp.Body.Close()
+		if err != nil {
+			b.Error(err)
+			return
+		}
+		response := strings.Trim(string(respBody), " \n")
+		if response != "Hello, Kevin!" {
+			b.Errorf("invalid response: '%s'", response)
+			return
+		}
+	}
+}
+
+func BenchmarkWeb(b *testing.B) {
+	benchStart(b, "webprov", func(in io.WriteCloser, out *bufio.Reader, errOut *bytes.Buffer) {
+		benchWeb(b, "http://localhost:8080", hub.NewWebClient(hub.WebOptions{}))
+	})
+}
+
+func BenchmarkWebH2C(b *testing.B) {
+	benchStart(b, "webprov-h2c", func(in io.WriteCloser, out *bufio.Reader, errOut *bytes.Buffer) {
+		benchWeb(b, "http://localhost:8082", hub.NewWebClient(hub.WebOptions{H2C: true}))
+	})
+}
+
+func BenchmarkWebSocket(b *testing.B) {
+	benchStart(b, "webprov-socket", func(in io.WriteCloser, out *bufio.Reader, errOut *bytes.Buffer) {
+		endpoint, socket := hub.SplitWebAddress("unix:///tmp/web_subcommand.sock")
+		benchWeb(b, endpoint, hub.NewWebClient(hub.WebOptions{Socket: socket}))
 	})
 }
 
 func BenchmarkGrpcTcp(b *testing.B) {
-	benchStart(b, []string{"build/grpcprov", "-network", "tcp", "-address", "localhost:8080"}, func(in io.WriteCloser, out *bufio.Reader, errOut *bytes.Buffer) {
-		var conn *grpc.ClientConn
-		var err error
-		for i := 0; i < 5; i++ {
-			<-time.After(250 * time.Millisecond)
-			conn, err = grpc.Dial(":8080", grpc.WithInsecure())
-			if err == nil {
-				break
-			}
-		}
+	benchStart(b, "grpcprov-tcp", func(in io.WriteCloser, out *bufio.Reader, errOut *bytes.Buffer) {
+		conn, err := grpc.Dial("localhost:8083", grpc.WithInsecure())
 		if err != nil {
 			b.Error(err)
 			return
@@ -299,16 +295,8 @@ func BenchmarkGrpcTcp(b *testing.B) {
 }
 
 func BenchmarkGrpcSocket(b *testing.B) {
-	benchStart(b, []string{"build/grpcprov"}, func(in io.WriteCloser, out *bufio.Reader, errOut *bytes.Buffer) {
-		var conn *grpc.ClientConn
-		var err error
-		for i := 0; i < 5; i++ {
-			<-time.After(250 * time.Millisecond)
-			conn, err = grpc.Dial("unix:////tmp/grpc_subcommand.sock", grpc.WithInsecure())
-			if err == nil {
-				break
-			}
-		}
+	benchStart(b, "grpcprov", func(in io.WriteCloser, out *bufio.Reader, errOut *bytes.Buffer) {
+		conn, err := grpc.Dial("unix:///tmp/grpcprov.sock", grpc.WithInsecure())
 		if err != nil {
 			b.Error(err)
 			return
@@ -331,20 +319,11 @@ func BenchmarkGrpcSocket(b *testing.B) {
 			}
 		}
 	})
-	os.Remove("/tmp/grpc_subcommand.sock")
 }
 
 func BenchmarkGrpcTcp_Stream(b *testing.B) {
-	benchStart(b, []string{"build/grpcprov", "-network", "tcp", "-address", "localhost:8080"}, func(in io.WriteCloser, out *bufio.Reader, errOut *bytes.Buffer) {
-		var err error
-		var conn *grpc.ClientConn
-		for i := 0; i < 5; i++ {
-			<-time.After(250 * time.Millisecond)
-			conn, err = grpc.Dial(":8080", grpc.WithInsecure())
-			if err == nil {
-				break
-			}
-		}
+	benchStart(b, "grpcprov-tcp", func(in io.WriteCloser, out *bufio.Reader, errOut *bytes.Buffer) {
+		conn, err := grpc.Dial("localhost:8083", grpc.WithInsecure())
 		if err != nil {
 			b.Error(err, errOut.String())
 			return
@@ -395,16 +374,8 @@ func BenchmarkGrpcTcp_Stream(b *testing.B) {
 }
 
 func BenchmarkGrpcSocket_Stream(b *testing.B) {
-	benchStart(b, []string{"build/grpcprov"}, func(in io.WriteCloser, out *bufio.Reader, errOut *bytes.Buffer) {
-		var err error
-		var conn *grpc.ClientConn
-		for i := 0; i < 5; i++ {
-			<-time.After(250 * time.Millisecond)
-			conn, err = grpc.Dial("unix:////tmp/grpc_subcommand.sock", grpc.WithInsecure())
-			if err == nil {
-				break
-			}
-		}
+	benchStart(b, "grpcprov", func(in io.WriteCloser, out *bufio.Reader, errOut *bytes.Buffer) {
+		conn, err := grpc.Dial("unix:///tmp/grpcprov.sock", grpc.WithInsecure())
 		if err != nil {
 			b.Error(err, errOut.String())
 			return
@@ -452,5 +423,4 @@ func BenchmarkGrpcSocket_Stream(b *testing.B) {
 		}
 		stream.CloseSend()
 	})
-	os.Remove("/tmp/grpc_subcommand.sock")
 }
@@ -0,0 +1,306 @@
+package manager
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"sync"
+	"time"
+
+	"github.com/subcommands_test/cli/lib"
+	"github.com/subcommands_test/config"
+	"github.com/subcommands_test/hub"
+	"github.com/subcommands_test/supervisor"
+	"google.golang.org/grpc"
+)
+
+// ErrNotConnected is returned for invocations of providers whose process
+// isn't connected yet, e.g. while it's restarted.
+var ErrNotConnected = errors.New("provider not connected")
+
+// instance is a single running provider. It's the hub.Provider handed out
+// by the Manager and counts the invocations in flight, so it can be drained
+// before it's stopped. The client is replaced whenever the supervisor
+// restarts the process.
+type instance struct {
+	cfg config.ProviderConfig
+	sup *supervisor.Supervisor
+	// ctx lives as long as the instance and ends streams and subscriptions
+	ctx    context.Context
+	cancel context.CancelFunc
+	events *hub.EventHub
+
+	mu       sync.Mutex
+	prov     hub.Provider
+	timeouts hub.Timeouts
+	// maxInFlight limits invocations, zero doesn't limit them
+	maxInFlight int
+	inFlight    int
+	// invocations counts the invocations among inFlight
+	invocations int
+	draining    bool
+	// idle is closed as soon as the instance is draining and nothing is in flight
+	idle chan struct{}
+}
+
+// start starts the provider of the config. Providers with Exec are started
+// with a supervisor and connected as soon as they're ready, others are
+// connected right away. Events pushed by the provider are passed to events
+// if it's not nil.
+func start(cfg config.ProviderConfig, stderr io.Writer, events *hub.EventHub) (*instance, error) {
+	ctx, cancel := context.WithCancel(context.Background())
+	inst := &instance{
+		cfg:    cfg,
+		ctx:    ctx,
+		cancel: cancel,
+		events: events,
+		idle:   make(chan struct{}),
+	}
+	if cfg.Exec == "" {
+		ctx, cancelDial := context.WithTimeout(ctx, 10*time.Second)
+		defer cancelDial()
+		if err := inst.dial(ctx); err != nil {
+			inst.close()
+			return nil, fmt.Errorf("provider %s: %w", cfg.Name, err)
+		}
+		return inst, nil
+	}
+
+	inst.sup = cfg.Supervisor(stderr)
+	if cfg.Transport.Stdio() {
+		inst.sup.Connect = func(stdin io.WriteCloser, stdout io.ReadCloser) error {
+			if cfg.Transport == config.TransportCliFramed {
+				inst.connect(hub.NewFramedCliProvider(stdin, stdout))
+			} else {
+				inst.connect(hub.NewCliProvider(stdin, stdout))
+			}
+			return nil
+		}
+		if cfg.Transport == config.TransportCliFramed {
+			inst.sup.Ready = func(ctx context.Context) error {
+				_, err := hub.Handshake(ctx, inst.current())
+				return err
+			}
+		}
+	} else {
+		inst.sup.Ready = inst.dial
+	}
+	if err := inst.sup.Start(); err != nil {
+		inst.close()
+		return nil, fmt.Errorf("provider %s: %w", cfg.Name, err)
+	}
+	return inst, nil
+}
+
+// dial connects to the address of a web or grpc provider and checks its
+// manifest.
+func (inst *instance) dial(ctx context.Context) error {
+	var prov hub.Provider
+	switch inst.cfg.Transport {
+	case config.TransportWeb:
+		endpoint, socket := hub.SplitWebAddress(inst.cfg.Address)
+		prov = &hub.WebProvider{
+			URL: endpoint,
+			Client: hub.NewWebClient(hub.WebOptions{
+				H2C:          inst.cfg.Web.H2C,
+				Socket:       socket,
+				MaxConns:     inst.cfg.Web.MaxConns,
+				MaxIdleConns: inst.cfg.Web.MaxIdleConns,
+				IdleTimeout:  inst.cfg.Web.IdleTimeout,
+			}),
+		}
+	case config.TransportGrpc, config.TransportGrpcStream:
+		conn, err := grpc.DialContext(ctx, inst.cfg.Address, grpc.WithInsecure())
+		if err != nil {
+			return err
+		}
+		if inst.cfg.Transport == config.TransportGrpc {
+			prov = hub.NewGrpcProvider(conn)
+		} else if prov, err = hub.NewGrpcStreamProvider(inst.ctx, conn); err != nil {
+			conn.Close()
+			return err
+		}
+	default:
+		return fmt.Errorf("transport %s can't be dialed", inst.cfg.Transport)
+	}
+	if _, err := hub.Handshake(ctx, prov); err != nil {
+		prov.Close()
+		return err
+	}
+	inst.connect(prov)
+	return nil
+}
+
+// connect replaces the client of the instance and subscribes to its events.
+func (inst *instance) connect(prov hub.Provider) {
+	inst.mu.Lock()
+	old := inst.prov
+	inst.prov = prov
+	inst.mu.Unlock()
+	if old != nil {
+		old.Close()
+	}
+	if source, ok := prov.(hub.EventSource); ok && inst.events != nil {
+		go inst.events.Subscribe(inst.ctx, inst.cfg.Name, source)
+	}
+}
+
+func (inst *instance) current() hub.Provider {
+	inst.mu.Lock()
+	defer inst.mu.Unlock()
+	return inst.prov
+}
+
+func (inst *instance) setTimeouts(timeouts hub.Timeouts) {
+	inst.mu.Lock()
+	defer inst.mu.Unlock()
+	inst.timeouts = timeouts
+}
+
+func (inst *instance) setMaxInFlight(max int) {
+	inst.mu.Lock()
+	defer inst.mu.Unlock()
+	inst.maxInFlight = max
+}
+
+// timed returns the client enforcing the timeouts of the instance.
+func (inst *instance) timed(prov hub.Provider) hub.StreamProvider {
+	inst.mu.Lock()
+	defer inst.mu.Unlock()
+	return hub.WithTimeouts(prov, inst.timeouts)
+}
+
+// acquire returns the client for an invocation, which has to be released
+// afterwards. Invocations beyond the max in flight fail with
+// CodeUnavailable. Describe and Health aren't invocations, so they're
+// answered anyway.
+func (inst *instance) acquire(invocation bool) (hub.Provider, error) {
+	inst.mu.Lock()
+	defer inst.mu.Unlock()
+	if inst.draining {
+		return nil, hub.ErrProviderClosed
+	}
+	if inst.prov == nil {
+		return nil, ErrNotConnected
+	}
+	if invocation {
+		if inst.maxInFlight > 0 && inst.invocations >= inst.maxInFlight {
+			return nil, lib.Errorf(lib.CodeUnavailable, "provider %s has %d invocations in flight already", inst.cfg.Name, inst.maxInFlight)
+		}
+		inst.invocations++
+	}
+	inst.inFlight++
+	return inst.prov, nil
+}
+
+func (inst *instance) release(invocation bool) {
+	inst.mu.Lock()
+	defer inst.mu.Unlock()
+	if invocation {
+		inst.invocations--
+	}
+	inst.inFlight--
+	if inst.draining && inst.inFlight == 0 {
+		close(inst.idle)
+	}
+}
+
+// Invoke delegates the invocation to the current client of the provider
+// with the timeout of the command.
+func (inst *instance) Invoke(ctx context.Context, command string, args []string) (string, error) {
+	prov, err := inst.acquire(true)
+	if err != nil {
+		return "", err
+	}
+	defer inst.release(true)
+	return inst.timed(prov).Invoke(ctx, command, args)
+}
+
+// InvokeRich delegates the invocation like Invoke.
+func (inst *instance) InvokeRich(ctx context.Context, command string, args []string) (*lib.Response, error) {
+	prov, err := inst.acquire(true)
+	if err != nil {
+		return nil, err
+	}
+	defer inst.release(true)
+	return inst.timed(prov).InvokeRich(ctx, command, args)
+}
+
+// InvokeStream delegates the invocation like Invoke.
+func (inst *instance) InvokeStream(ctx context.Context, command string, args []string, chunks func(*lib.Response)) (*lib.Response, error) {
+	prov, err := inst.acquire(true)
+	if err != nil {
+		return nil, err
+	}
+	defer inst.release(true)
+	return inst.timed(prov).InvokeStream(ctx, command, args, chunks)
+}
+
+// Describe returns the manifest of the current client.
+func (inst *instance) Describe(ctx context.Context) (*lib.Manifest, error) {
+	prov, err := inst.acquire(false)
+	if err != nil {
+		return nil, err
+	}
+	defer inst.release(false)
+	return prov.Describe(ctx)
+}
+
+// Health checks the current client if it's a hub.HealthChecker. Others are
+// healthy as long as they're connected.
+func (inst *instance) Health(ctx context.Context) error {
+	prov, err := inst.acquire(false)
+	if err != nil {
+		return err
+	}
+	defer inst.release(false)
+	if checker, ok := prov.(hub.HealthChecker); ok {
+		return checker.Health(ctx)
+	}
+	return nil
+}
+
+// Close does nothing, the instance is closed by the Manager.
+func (inst *instance) Close() error {
+	return nil
+}
+
+// drain refuses further invocations and waits up to the grace period for
+// the ones in flight before the provider is stopped. It returns the number
+// of abandoned invocations.
+func (inst *instance) drain(grace time.Duration) (abandoned int) {
+	inst.mu.Lock()
+	if !inst.draining {
+		inst.draining = true
+		if inst.inFlight == 0 {
+			close(inst.idle)
+		}
+	}
+	inst.mu.Unlock()
+
+	select {
+	case <-inst.idle:
+	case <-time.After(grace):
+	}
+	inst.mu.Lock()
+	abandoned = inst.inFlight
+	inst.mu.Unlock()
+	inst.close()
+	return abandoned
+}
+
+// close stops the process and closes the client without waiting.
+func (inst *instance) close() {
+	inst.cancel()
+	if inst.sup != nil {
+		inst.sup.Stop()
+	}
+	inst.mu.Lock()
+	prov := inst.prov
+	inst.prov = nil
+	inst.mu.Unlock()
+	if prov != nil {
+		prov.Close()
+	}
+}
@@ -0,0 +1,371 @@
+// Package manager runs the providers listed in the config of the hub. The
+// running set is reloaded without restarting the hub: new providers are
+// started, removed ones drained and changed ones reconnected, while
+// unchanged providers keep their connections.
+package manager
+
+import (
+	"errors"
+	"io"
+	"reflect"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/subcommands_test/config"
+	"github.com/subcommands_test/hub"
+)
+
+// ErrClosed is returned when reloading a closed Manager.
+var ErrClosed = errors.New("manager closed")
+
+// Manager starts and stops the providers of a config.Config.
+//
+// Providers are replaced without interrupting the chat: invocations in
+// flight on a removed or changed provider get GracePeriod to finish while
+// new invocations are routed to its replacement.
+type Manager struct {
+	// Stderr receives the stderr of all started processes.
+	Stderr io.Writer
+	// GracePeriod is the time in-flight invocations of stopped providers
+	// have to finish. Defaults to 5s.
+	GracePeriod time.Duration
+	// Events accepts the events of the providers if set. Providers are
+	// registered with the token and limits of their config.
+	Events *hub.EventHub
+	// Prober checks the health of the providers if set.
+	Prober *hub.Prober
+	// Router routes chat messages to the commands of the providers if set.
+	// The prefix and aliases of the config are applied to it.
+	Router *hub.Router
+	// OnStop is called whenever a provider was stopped with the number of
+	// invocations abandoned after the grace period.
+	OnStop func(name string, abandoned int)
+
+	// reloadMu serializes reloads and guards configs
+	reloadMu sync.Mutex
+	configs  map[string]config.ProviderConfig
+	drains   sync.WaitGroup
+
+	mu      sync.RWMutex
+	running map[string]*instance
+	// owners maps lower-case command names to the name of their provider
+	owners map[string]string
+	closed bool
+}
+
+// Provider returns the running provider with the name. It stays usable
+// until the provider is removed or changed by a reload, afterwards its
+// invocations fail with hub.ErrProviderClosed.
+func (m *Manager) Provider(name string) (hub.Provider, bool) {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+	inst, ok := m.running[name]
+	if !ok {
+		return nil, false
+	}
+	return inst, true
+}
+
+// Owner returns the running provider owning the command. Command names are
+// case-insensitive.
+func (m *Manager) Owner(command string) (hub.Provider, bool) {
+	m.mu.RLock()
+	name, ok := m.owners[strings.ToLower(command)]
+	m.mu.RUnlock()
+	if !ok {
+		return nil, false
+	}
+	return m.Provider(name)
+}
+
+// Names returns the names of all running providers.
+func (m *Manager) Names() []string {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+	names := make([]string, 0, len(m.running))
+	for name := range m.running {
+		names = append(names, name)
+	}
+	return names
+}
+
+func (m *Manager) gracePeriod() time.Duration {
+	if m.GracePeriod <= 0 {
+		return 5 * time.Second
+	}
+	return m.GracePeriod
+}
+
+// replacement is a changed provider whose process listens on the same
+// address as before, so the old one has to be stopped before the new one
+// is started.
+type replacement struct {
+	old    *instance
+	oldCfg config.ProviderConfig
+	cfg    config.ProviderConfig
+	new    *instance
+}
+
+// Reload makes the config the running one. Unchanged providers are kept,
+// providers whose commands, routing, timeouts or limits changed are updated in place
+// and all other changed providers are reconnected.
+//
+// If a provider fails to start the reload is rolled back and the previous
+// config keeps running. Removed and replaced providers are drained in the
+// background.
+func (m *Manager) Reload(cfg *config.Config) error {
+	if err := cfg.Validate(); err != nil {
+		return err
+	}
+	m.reloadMu.Lock()
+	defer m.reloadMu.Unlock()
+
+	m.mu.RLock()
+	closed := m.closed
+	current := m.running
+	m.mu.RUnlock()
+	if closed {
+		return ErrClosed
+	}
+
+	next := make(map[string]*instance, len(cfg.Providers))
+	var started, replaced []*instance
+	var exclusive []*replacement
+	rollback := func() {
+		for _, inst := range started {
+			inst.close()
+		}
+	}
+	for _, prov := range cfg.Providers {
+		old, ok := current[prov.Name]
+		oldCfg := m.configs[prov.Name]
+		switch {
+		case ok && sameConnection(oldCfg, prov):
+			next[prov.Name] = old
+		case ok && sameListener(oldCfg, prov):
+			exclusive = append(exclusive, &replacement{old: old, oldCfg: oldCfg, cfg: prov})
+		default:
+			inst, err := start(prov, m.Stderr, m.Events)
+			if err != nil {
+				rollback()
+				return err
+			}
+			started = append(started, inst)
+			next[prov.Name] = inst
+			if ok {
+				replaced = append(replaced, old)
+			}
+		}
+	}
+
+	// Providers listening on the same address are replaced one after
+	// another. If one fails to start all of them get their old config back.
+	for i, repl := range exclusive {
+		m.stopped(repl.old)
+		inst, err := start(repl.cfg, m.Stderr, m.Events)
+		if err == nil {
+			repl.new = inst
+			next[repl.cfg.Name] = inst
+			continue
+		}
+		rollback()
+		m.restore(current, exclusive[:i+1])
+		return err
+	}
+
+	m.mu.Lock()
+	m.running = next
+	m.owners = make(map[string]string)
+	for _, prov := range cfg.Providers {
+		for _, command := range prov.Commands {
+			m.owners[strings.ToLower(command)] = prov.Name
+		}
+	}
+	m.mu.Unlock()
+
+	for name, inst := range current {
+		if _, ok := next[name]; !ok {
+			m.unregister(name)
+			replaced = append(replaced, inst)
+		}
+	}
+	var added, updated []config.ProviderConfig
+	configs := make(map[string]config.ProviderConfig, len(cfg.Providers))
+	for _, prov := range cfg.Providers {
+		configs[prov.Name] = prov
+		if current[prov.Name] != next[prov.Name] {
+			added = append(added, prov)
+		} else if !reflect.DeepEqual(m.configs[prov.Name], prov) {
+			updated = append(updated, prov)
+		}
+		if m.Router != nil && (current[prov.Name] != next[prov.Name] || !reflect.DeepEqual(m.configs[prov.Name].Commands, prov.Commands) || m.configs[prov.Name].Multi != prov.Multi) {
+			// Commands may move between providers, so all of them are
+			// unregistered before any of them is registered again
+			m.Router.Unregister(prov.Name)
+		}
+	}
+	m.configs = configs
+	if m.Router != nil {
+		m.route(cfg.Router)
+	}
+	for _, prov := range updated {
+		m.register(prov)
+	}
+	for _, prov := range added {
+		m.add(prov)
+	}
+	for _, inst := range replaced {
+		m.stop(inst)
+	}
+	return nil
+}
+
+// restore restarts the drained providers of the replacements with their
+// old config after a failed reload.
+func (m *Manager) restore(current map[string]*instance, replacements []*replacement) {
+	for _, repl := range replacements {
+		if repl.new != nil {
+			repl.new.close()
+		}
+		name := repl.oldCfg.Name
+		inst, err := start(repl.oldCfg, m.Stderr, m.Events)
+		m.mu.Lock()
+		if err == nil {
+			current[name] = inst
+		} else {
+			delete(current, name)
+		}
+		m.mu.Unlock()
+		if err == nil {
+			m.add(repl.oldCfg)
+		} else {
+			m.unregister(name)
+		}
+	}
+}
+
+// add passes a started provider to the Prober and registers it at the
+// EventHub. Must be called after it was made running.
+func (m *Manager) add(cfg config.ProviderConfig) {
+	if m.Prober != nil {
+		if prov, ok := m.Provider(cfg.Name); ok {
+			m.Prober.Add(cfg.Name, prov.(hub.HealthChecker))
+		}
+	}
+	m.register(cfg)
+}
+
+// register applies the timeouts and max in flight of the config to the
+// provider, allows it to push events with its token and limits and routes
+// its commands to it.
+func (m *Manager) register(cfg config.ProviderConfig) {
+	m.mu.RLock()
+	if inst, ok := m.running[cfg.Name]; ok {
+		inst.setTimeouts(hub.Timeouts{
+			Default:  cfg.Timeout,
+			Commands: cfg.Timeouts,
+		})
+		inst.setMaxInFlight(cfg.Limits.MaxInFlight)
+	}
+	m.mu.RUnlock()
+	if m.Events != nil {
+		m.Events.Register(cfg.Name, cfg.Limits.EventToken, hub.EventLimit{
+			Rate:  cfg.Limits.EventRate,
+			Burst: cfg.Limits.EventBurst,
+		})
+	}
+	if prov, ok := m.Provider(cfg.Name); ok && m.Router != nil {
+		// Conflicts are refused by the validation of the config
+		if cfg.Multi {
+			m.Router.RegisterNamed(cfg.Name, prov, cfg.Commands...)
+		} else {
+			m.Router.Register(cfg.Name, prov, cfg.Commands...)
+		}
+	}
+}
+
+// route applies the prefixes and aliases of the config to the Router.
+func (m *Manager) route(cfg config.RouterConfig) {
+	m.Router.ResetChannels()
+	m.Router.SetPrefix("", cfg.Prefix)
+	for alias, command := range cfg.Aliases {
+		m.Router.Alias("", alias, command)
+	}
+	for channel, override := range cfg.Channels {
+		m.Router.SetPrefix(channel, override.Prefix)
+		for alias, command := range override.Aliases {
+			m.Router.Alias(channel, alias, command)
+		}
+	}
+}
+
+func (m *Manager) unregister(name string) {
+	if m.Prober != nil {
+		m.Prober.Remove(name)
+	}
+	if m.Router != nil {
+		m.Router.Unregister(name)
+	}
+	if m.Events != nil {
+		m.Events.Unregister(name)
+	}
+}
+
+// stop drains the instance in the background.
+func (m *Manager) stop(inst *instance) {
+	m.drains.Add(1)
+	go func() {
+		defer m.drains.Done()
+		m.stopped(inst)
+	}()
+}
+
+// stopped drains the instance and reports it to OnStop.
+func (m *Manager) stopped(inst *instance) {
+	abandoned := inst.drain(m.gracePeriod())
+	if m.OnStop != nil {
+		m.OnStop(inst.cfg.Name, abandoned)
+	}
+}
+
+// Close drains all providers and waits until they're stopped.
+func (m *Manager) Close() error {
+	m.reloadMu.Lock()
+	defer m.reloadMu.Unlock()
+
+	m.mu.Lock()
+	if m.closed {
+		m.mu.Unlock()
+		return nil
+	}
+	m.closed = true
+	running := m.running
+	m.running = nil
+	m.owners = nil
+	m.mu.Unlock()
+
+	for name, inst := range running {
+		m.unregister(name)
+		m.stop(inst)
+	}
+	m.drains.Wait()
+	return nil
+}
+
+// sameConnection reports whether the providers only differ in settings
+// applied by the hub, which don't need a new connection.
+func sameConnection(old, new config.ProviderConfig) bool {
+	old.Commands, new.Commands = nil, nil
+	old.Multi, new.Multi = false, false
+	old.Timeout, new.Timeout = 0, 0
+	old.Timeouts, new.Timeouts = nil, nil
+	old.Limits, new.Limits = config.LimitsConfig{}, config.LimitsConfig{}
+	return reflect.DeepEqual(old, new)
+}
+
+// sameListener reports whether both providers are started by the hub and
+// listen on the same address, so they can't run at the same time.
+func sameListener(old, new config.ProviderConfig) bool {
+	return old.Exec != "" && new.Exec != "" && !old.Transport.Stdio() && !new.Transport.Stdio() && old.Address == new.Address
+}
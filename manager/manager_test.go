@@ -0,0 +1,333 @@
+package manager
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/subcommands_test/cli/lib"
+	"github.com/subcommands_test/config"
+	"github.com/subcommands_test/hub"
+)
+
+// TestHelperProcess isn't a real test. It's started as provider process by the other tests.
+func TestHelperProcess(t *testing.T) {
+	if os.Getenv("GO_WANT_HELPER_PROCESS") != "1" {
+		return
+	}
+	provider := &lib.ReaderWriterProvider{
+		Input:       os.Stdin,
+		Output:      os.Stdout,
+		Framed:      true,
+		MaxInFlight: 4,
+		HandlerFunc: func(args []string) string {
+			if len(args) > 0 && args[0] == "slow" {
+				time.Sleep(200 * time.Millisecond)
+			}
+			return os.Getenv("GREETING") + " " + strings.Join(args, " ")
+		},
+	}
+	done := provider.Start()
+	lib.AnnounceReady(os.Stderr)
+	<-done
+	os.Exit(0)
+}
+
+// helperConfig returns the YAML config of framed helper providers, each
+// greeting with the given greeting.
+func helperConfig(greetings map[string]string) string {
+	var cfg strings.Builder
+	cfg.WriteString("providers:\n")
+	for name, greeting := range greetings {
+		fmt.Fprintf(&cfg, `  - name: %s
+    transport: cli-framed
+    exec: %s
+    args: ["-test.run=TestHelperProcess"]
+    env:
+      GO_WANT_HELPER_PROCESS: "1"
+      GREETING: %s
+    commands: [%s]
+    restart:
+      policy: never
+`, name, os.Args[0], greeting, name)
+	}
+	return cfg.String()
+}
+
+func parse(t *testing.T, data string) *config.Config {
+	cfg, err := config.Parse([]byte(data))
+	if err != nil {
+		t.Fatal(err)
+	}
+	return cfg
+}
+
+func invoke(t *testing.T, m *Manager, command string) string {
+	t.Helper()
+	prov, ok := m.Owner(command)
+	if !ok {
+		t.Fatalf("no provider owns %s", command)
+	}
+	result, err := prov.Invoke(context.Background(), "", []string{"Kevin"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	return result
+}
+
+func TestManager_Reload(t *testing.T) {
+	var mu sync.Mutex
+	stopped := make(map[string]int)
+	m := &Manager{
+		GracePeriod: time.Second,
+		OnStop: func(name string, abandoned int) {
+			mu.Lock()
+			defer mu.Unlock()
+			stopped[name]++
+		},
+	}
+	defer m.Close()
+
+	if err := m.Reload(parse(t, helperConfig(map[string]string{"hello": "Hello", "hi": "Hi"}))); err != nil {
+		t.Fatal(err)
+	}
+	if result := invoke(t, m, "HELLO"); result != "Hello Kevin" {
+		t.Errorf("invalid result '%s'", result)
+	}
+	unchanged, _ := m.Provider("hello")
+	changed, _ := m.Provider("hi")
+
+	// A slow invocation on the changed provider has to finish
+	slow := make(chan error, 1)
+	go func() {
+		result, err := changed.Invoke(context.Background(), "", []string{"slow"})
+		if err == nil && result != "Hi slow" {
+			err = fmt.Errorf("invalid result '%s'", result)
+		}
+		slow <- err
+	}()
+	time.Sleep(50 * time.Millisecond)
+
+	if err := m.Reload(parse(t, helperConfig(map[string]string{"hello": "Hello", "hi": "Howdy", "hey": "Hey"}))); err != nil {
+		t.Fatal(err)
+	}
+	if prov, _ := m.Provider("hello"); prov != unchanged {
+		t.Error("unchanged provider was restarted")
+	}
+	if result := invoke(t, m, "hi"); result != "Howdy Kevin" {
+		t.Errorf("changed provider not reconnected, got '%s'", result)
+	}
+	if result := invoke(t, m, "hey"); result != "Hey Kevin" {
+		t.Errorf("invalid result of added provider '%s'", result)
+	}
+	if err := <-slow; err != nil {
+		t.Errorf("in-flight invocation of changed provider failed: %v", err)
+	}
+
+	if err := m.Reload(parse(t, helperConfig(map[string]string{"hello": "Hello"}))); err != nil {
+		t.Fatal(err)
+	}
+	if _, ok := m.Owner("hey"); ok {
+		t.Error("removed provider is still running")
+	}
+	m.Close()
+	mu.Lock()
+	defer mu.Unlock()
+	if stopped["hi"] != 2 || stopped["hey"] != 1 || stopped["hello"] != 1 {
+		t.Errorf("invalid stopped providers %v", stopped)
+	}
+	if _, err := unchanged.Invoke(context.Background(), "", nil); err != hub.ErrProviderClosed {
+		t.Errorf("expected ErrProviderClosed after close, got %v", err)
+	}
+}
+
+func TestManager_ReloadFailure(t *testing.T) {
+	m := &Manager{GracePeriod: time.Second}
+	defer m.Close()
+	if err := m.Reload(parse(t, helperConfig(map[string]string{"hello": "Hello"}))); err != nil {
+		t.Fatal(err)
+	}
+	prov, _ := m.Provider("hello")
+
+	broken := helperConfig(map[string]string{"hello": "Hi"}) + `  - name: broken
+    transport: cli
+    exec: /does/not/exist
+`
+	if err := m.Reload(parse(t, broken)); err == nil {
+		t.Fatal("expected reload to fail")
+	}
+	if current, _ := m.Provider("hello"); current != prov {
+		t.Error("previous provider was replaced by failed reload")
+	}
+	if _, ok := m.Provider("broken"); ok {
+		t.Error("broken provider is running")
+	}
+	if result := invoke(t, m, "hello"); result != "Hello Kevin" {
+		t.Errorf("invalid result '%s'", result)
+	}
+}
+
+func TestManager_Watch(t *testing.T) {
+	dir, err := ioutil.TempDir("", "manager")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	path := filepath.Join(dir, "providers.yaml")
+	if err := ioutil.WriteFile(path, []byte(helperConfig(map[string]string{"hello": "Hello"})), 0600); err != nil {
+		t.Fatal(err)
+	}
+
+	m := &Manager{GracePeriod: time.Second}
+	defer m.Close()
+	if err := m.Load(path); err != nil {
+		t.Fatal(err)
+	}
+	reloads := make(chan error, 2)
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	go m.Watch(ctx, path, 10*time.Millisecond, func(err error) {
+		reloads <- err
+	})
+
+	time.Sleep(20 * time.Millisecond)
+	if err := ioutil.WriteFile(path, []byte("providers: [{name: hello}]\n"), 0600); err != nil {
+		t.Fatal(err)
+	}
+	if err := <-reloads; err == nil {
+		t.Error("expected invalid config to be refused")
+	}
+	if result := invoke(t, m, "hello"); result != "Hello Kevin" {
+		t.Errorf("invalid result after failed reload '%s'", result)
+	}
+
+	if err := ioutil.WriteFile(path, []byte(helperConfig(map[string]string{"hello": "Hi"})), 0600); err != nil {
+		t.Fatal(err)
+	}
+	select {
+	case err := <-reloads:
+		if err != nil {
+			t.Fatal(err)
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatal("file change wasn't noticed")
+	}
+	if result := invoke(t, m, "hello"); result != "Hi Kevin" {
+		t.Errorf("invalid result after reload '%s'", result)
+	}
+}
+
+func TestManager_Router(t *testing.T) {
+	router := &hub.Router{}
+	m := &Manager{GracePeriod: time.Second, Router: router}
+	defer m.Close()
+
+	cfg := "router:\n  aliases:\n    hey: hi\n" + helperConfig(map[string]string{"hello": "Hello", "hi": "Hi"})
+	if err := m.Reload(parse(t, cfg)); err != nil {
+		t.Fatal(err)
+	}
+	resp, err := router.Handle(context.Background(), "!hey Kevin")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if resp.String() != "Hi Kevin" {
+		t.Errorf("invalid result '%s'", resp.String())
+	}
+
+	// The alias becomes a command and the prefix changes
+	cfg = "router:\n  prefix: '?'\n" + helperConfig(map[string]string{"hello": "Hello", "hey": "Hey"})
+	if err := m.Reload(parse(t, cfg)); err != nil {
+		t.Fatal(err)
+	}
+	resp, err = router.Handle(context.Background(), "?hey Kevin")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if resp.String() != "Hey Kevin" {
+		t.Errorf("invalid result '%s'", resp.String())
+	}
+	if _, err := router.Route("", "?hi"); err == nil {
+		t.Error("command of removed provider is still routed")
+	}
+
+	// Providers routing by name get the command name without reconnecting
+	prov, _ := m.Provider("hey")
+	cfg = strings.Replace(cfg, "    commands: [hey]\n", "    commands: [hey]\n    multi: true\n", 1)
+	if err := m.Reload(parse(t, cfg)); err != nil {
+		t.Fatal(err)
+	}
+	if current, _ := m.Provider("hey"); current != prov {
+		t.Error("provider was reconnected for changed routing")
+	}
+	resp, err = router.Handle(context.Background(), "?hey Kevin")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if resp.String() != "Hey hey Kevin" {
+		t.Errorf("invalid result of multi provider '%s'", resp.String())
+	}
+}
+
+func TestManager_Timeout(t *testing.T) {
+	m := &Manager{GracePeriod: time.Second}
+	defer m.Close()
+	withTimeout := func(timeout string) *config.Config {
+		cfg := helperConfig(map[string]string{"hello": "Hello"})
+		return parse(t, strings.Replace(cfg, "    restart:", "    timeouts:\n      slow: "+timeout+"\n    restart:", 1))
+	}
+	if err := m.Reload(withTimeout("50ms")); err != nil {
+		t.Fatal(err)
+	}
+	prov, _ := m.Provider("hello")
+	if _, err := prov.Invoke(context.Background(), "", []string{"slow"}); !errors.Is(err, hub.ErrTimeout) {
+		t.Errorf("expected ErrTimeout, got %v", err)
+	}
+
+	// Timeouts are changed without reconnecting
+	if err := m.Reload(withTimeout("1s")); err != nil {
+		t.Fatal(err)
+	}
+	if current, _ := m.Provider("hello"); current != prov {
+		t.Error("provider was reconnected for changed timeouts")
+	}
+	if result, err := prov.Invoke(context.Background(), "", []string{"slow"}); err != nil || result != "Hello slow" {
+		t.Errorf("invalid result '%s' %v", result, err)
+	}
+}
+
+func TestManager_MaxInFlight(t *testing.T) {
+	m := &Manager{GracePeriod: time.Second}
+	defer m.Close()
+	cfg := helperConfig(map[string]string{"hello": "Hello"})
+	cfg = strings.Replace(cfg, "    restart:", "    limits:\n      max_in_flight: 1\n    restart:", 1)
+	if err := m.Reload(parse(t, cfg)); err != nil {
+		t.Fatal(err)
+	}
+	prov, _ := m.Provider("hello")
+
+	slow := make(chan error, 1)
+	go func() {
+		_, err := prov.Invoke(context.Background(), "", []string{"slow"})
+		slow <- err
+	}()
+	time.Sleep(50 * time.Millisecond)
+	if _, err := prov.Invoke(context.Background(), "", []string{"Kevin"}); lib.AsError(err).Code != lib.CodeUnavailable {
+		t.Errorf("expected CodeUnavailable beyond max in flight, got %v", err)
+	}
+	if _, err := prov.Describe(context.Background()); err != nil {
+		t.Errorf("describe was limited: %v", err)
+	}
+	if err := <-slow; err != nil {
+		t.Fatal(err)
+	}
+	if result := invoke(t, m, "hello"); result != "Hello Kevin" {
+		t.Errorf("invalid result after slow invocation finished '%s'", result)
+	}
+}
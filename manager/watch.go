@@ -0,0 +1,71 @@
+package manager
+
+import (
+	"context"
+	"os"
+	"os/signal"
+	"syscall"
+	"time"
+
+	"github.com/subcommands_test/config"
+)
+
+// Load reads the config file and reloads the Manager with it.
+func (m *Manager) Load(path string) error {
+	cfg, err := config.Load(path)
+	if err != nil {
+		return err
+	}
+	return m.Reload(cfg)
+}
+
+// Watch reloads the config file on SIGHUP and whenever it's modified,
+// which is checked every interval. The result of every reload is passed to
+// report, failed reloads keep the previous config running. Watch returns
+// when ctx is done.
+func (m *Manager) Watch(ctx context.Context, path string, interval time.Duration, report func(error)) {
+	if interval <= 0 {
+		interval = time.Second
+	}
+	hup := make(chan os.Signal, 1)
+	signal.Notify(hup, syscall.SIGHUP)
+	defer signal.Stop(hup)
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	reload := func() {
+		err := m.Load(path)
+		if report != nil {
+			report(err)
+		}
+	}
+	modified := modification(path)
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-hup:
+			modified = modification(path)
+			reload()
+		case <-ticker.C:
+			if current := modification(path); current != modified {
+				modified = current
+				reload()
+			}
+		}
+	}
+}
+
+// fileVersion identifies a version of a file by its modification time and size.
+type fileVersion struct {
+	modTime time.Time
+	size    int64
+}
+
+func modification(path string) fileVersion {
+	info, err := os.Stat(path)
+	if err != nil {
+		return fileVersion{}
+	}
+	return fileVersion{info.ModTime(), info.Size()}
+}
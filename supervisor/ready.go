@@ -0,0 +1,58 @@
+package supervisor
+
+import (
+	"bytes"
+	"io"
+	"sync"
+)
+
+// lineWatcher forwards everything written to it and signals when a line
+// equal to the expected one was written.
+type lineWatcher struct {
+	w    io.Writer
+	line []byte
+
+	mu    sync.Mutex
+	buf   []byte
+	found chan struct{}
+}
+
+func newLineWatcher(w io.Writer, line string) *lineWatcher {
+	return &lineWatcher{
+		w:     w,
+		line:  []byte(line),
+		found: make(chan struct{}),
+	}
+}
+
+func (watcher *lineWatcher) Write(p []byte) (int, error) {
+	watcher.mu.Lock()
+	watcher.scan(p)
+	watcher.mu.Unlock()
+	if watcher.w == nil {
+		return len(p), nil
+	}
+	return watcher.w.Write(p)
+}
+
+func (watcher *lineWatcher) scan(p []byte) {
+	select {
+	case <-watcher.found:
+		return
+	default:
+	}
+	watcher.buf = append(watcher.buf, p...)
+	for {
+		i := bytes.IndexByte(watcher.buf, '\n')
+		if i < 0 {
+			return
+		}
+		line := bytes.TrimSpace(watcher.buf[:i])
+		watcher.buf = watcher.buf[i+1:]
+		if bytes.Equal(line, watcher.line) {
+			close(watcher.found)
+			watcher.buf = nil
+			return
+		}
+	}
+}
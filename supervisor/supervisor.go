@@ -0,0 +1,386 @@
+// Package supervisor starts provider processes and keeps them running.
+// Crashed processes are restarted with an exponential backoff until the
+// restart budget of their RestartPolicy is used up.
+package supervisor
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"os/exec"
+	"sync"
+	"time"
+)
+
+// State is the lifecycle state of a supervised process.
+type State int
+
+const (
+	// StateStarting means the process is being started.
+	StateStarting State = iota
+	// StateReady means the process is running, connected and announced
+	// that it's ready.
+	StateReady
+	// StateCrashed means the process exited unexpectedly or failed to start.
+	// It's restarted if the RestartPolicy allows it.
+	StateCrashed
+	// StateStopped means the process was stopped by Stop or exited with
+	// status 0 while the RestartPolicy only restarts on failure.
+	StateStopped
+)
+
+var stateNames = map[State]string{
+	StateStarting: "starting",
+	StateReady:    "ready",
+	StateCrashed:  "crashed",
+	StateStopped:  "stopped",
+}
+
+func (state State) String() string {
+	if name, ok := stateNames[state]; ok {
+		return name
+	}
+	return fmt.Sprintf("state(%d)", int(state))
+}
+
+var (
+	// ErrStarted is returned when starting a supervisor twice.
+	ErrStarted = errors.New("supervisor already started")
+	// ErrNotReady is returned if a process didn't get ready in time.
+	ErrNotReady = errors.New("process not ready in time")
+)
+
+// RestartPolicy configures how crashed processes are restarted.
+type RestartPolicy struct {
+	// MaxRestarts is the number of restarts allowed before giving up.
+	// Zero disables restarts, a negative value allows unlimited restarts.
+	MaxRestarts int
+	// InitialBackoff is the delay before the first restart. It's doubled
+	// for every further restart up to MaxBackoff. Defaults to 100ms.
+	InitialBackoff time.Duration
+	// MaxBackoff caps the delay between restarts. Defaults to 30s.
+	MaxBackoff time.Duration
+	// ResetAfter resets the used restarts if a process ran at least this
+	// long before crashing. Zero never resets them.
+	ResetAfter time.Duration
+	// OnlyOnFailure only restarts processes which failed. Processes exiting
+	// with status 0 aren't restarted but stopped.
+	OnlyOnFailure bool
+}
+
+func (policy RestartPolicy) backoff(restarts int) time.Duration {
+	backoff := policy.InitialBackoff
+	if backoff <= 0 {
+		backoff = 100 * time.Millisecond
+	}
+	max := policy.MaxBackoff
+	if max <= 0 {
+		max = 30 * time.Second
+	}
+	for i := 0; i < restarts && backoff < max; i++ {
+		backoff *= 2
+	}
+	if backoff > max {
+		return max
+	}
+	return backoff
+}
+
+// Supervisor supervises a single provider process.
+type Supervisor struct {
+	Name string
+	// Path and Args of the executable.
+	Path string
+	Args []string
+	// Env of the process. If nil the environment of the hub is used.
+	Env    []string
+	Stderr io.Writer
+
+	Policy RestartPolicy
+	// StopTimeout is the time a process gets to exit after being interrupted
+	// before it's killed. Defaults to one second.
+	StopTimeout time.Duration
+
+	// Connect is called with the stdin and stdout of every started process,
+	// e.g. to create a hub.FramedCliProvider. If it fails the process is killed
+	// and treated as crashed. If Connect is nil stdin and stdout aren't used.
+	Connect func(stdin io.WriteCloser, stdout io.ReadCloser) error
+	// ReadyLine is the line the process writes to stderr as soon as it's
+	// ready, usually lib.ReadyLine. If empty the process doesn't announce it.
+	ReadyLine string
+	// Ready is called after the process announced it's ready, e.g. to check
+	// its health endpoint. It's retried until it succeeds or ReadyTimeout passed.
+	Ready func(ctx context.Context) error
+	// ReadyTimeout is the time a process gets to get ready. Defaults to 10s.
+	ReadyTimeout time.Duration
+
+	// OnStateChange is called for every state change.
+	OnStateChange func(name string, state State)
+
+	mu       sync.Mutex
+	state    State
+	err      error
+	restarts int
+	stopped  bool
+	stop     chan struct{}
+	done     chan struct{}
+}
+
+// State returns the current state of the process.
+func (sup *Supervisor) State() State {
+	sup.mu.Lock()
+	defer sup.mu.Unlock()
+	return sup.state
+}
+
+// Err returns the reason of the last crash.
+func (sup *Supervisor) Err() error {
+	sup.mu.Lock()
+	defer sup.mu.Unlock()
+	return sup.err
+}
+
+// Restarts returns the number of restarts since the budget was last reset.
+func (sup *Supervisor) Restarts() int {
+	sup.mu.Lock()
+	defer sup.mu.Unlock()
+	return sup.restarts
+}
+
+// Done is closed when the supervision ended, either because the process
+// was stopped or because the restart budget was used up.
+func (sup *Supervisor) Done() <-chan struct{} {
+	sup.mu.Lock()
+	defer sup.mu.Unlock()
+	return sup.done
+}
+
+func (sup *Supervisor) setState(state State, err error) {
+	sup.mu.Lock()
+	sup.state = state
+	if err != nil {
+		sup.err = err
+	}
+	sup.mu.Unlock()
+	if sup.OnStateChange != nil {
+		sup.OnStateChange(sup.Name, state)
+	}
+}
+
+// Start starts the process and supervises it until Stop is called.
+// If the first start fails no restarts are attempted.
+func (sup *Supervisor) Start() error {
+	sup.mu.Lock()
+	if sup.done != nil {
+		sup.mu.Unlock()
+		return ErrStarted
+	}
+	sup.stop = make(chan struct{})
+	sup.done = make(chan struct{})
+	sup.mu.Unlock()
+
+	cmd, exited, err := sup.launch()
+	if err != nil {
+		sup.setState(StateCrashed, err)
+		close(sup.done)
+		return err
+	}
+	go sup.supervise(cmd, exited)
+	return nil
+}
+
+// launch starts and connects the process. The returned channel receives
+// the result of cmd.Wait.
+func (sup *Supervisor) launch() (*exec.Cmd, <-chan error, error) {
+	sup.setState(StateStarting, nil)
+	cmd := exec.Command(sup.Path, sup.Args...)
+	cmd.Env = sup.Env
+	cmd.Stderr = sup.Stderr
+	var watcher *lineWatcher
+	if sup.ReadyLine != "" {
+		watcher = newLineWatcher(sup.Stderr, sup.ReadyLine)
+		cmd.Stderr = watcher
+	}
+
+	// Pipes are created manually, as the ones of exec.Cmd are closed by Wait
+	// while the hub might still read from them.
+	var stdin, stdout, childIn, childOut *os.File
+	if sup.Connect != nil {
+		var err error
+		childIn, stdin, err = os.Pipe()
+		if err != nil {
+			return nil, nil, err
+		}
+		stdout, childOut, err = os.Pipe()
+		if err != nil {
+			childIn.Close()
+			stdin.Close()
+			return nil, nil, err
+		}
+		cmd.Stdin = childIn
+		cmd.Stdout = childOut
+	}
+
+	err := cmd.Start()
+	if sup.Connect != nil {
+		// The child has its own copies now
+		childIn.Close()
+		childOut.Close()
+		if err != nil {
+			stdin.Close()
+			stdout.Close()
+		}
+	}
+	if err != nil {
+		return nil, nil, err
+	}
+	exited := make(chan error, 1)
+	go func() {
+		exited <- cmd.Wait()
+	}()
+
+	err = sup.connect(stdin, stdout, watcher, exited)
+	if err != nil {
+		cmd.Process.Kill()
+		<-exited
+		return nil, nil, err
+	}
+	sup.setState(StateReady, nil)
+	return cmd, exited, nil
+}
+
+// connect connects the started process and waits until it's ready.
+func (sup *Supervisor) connect(stdin io.WriteCloser, stdout io.ReadCloser, watcher *lineWatcher, exited chan error) error {
+	if sup.Connect != nil {
+		if err := sup.Connect(stdin, stdout); err != nil {
+			return err
+		}
+	}
+
+	timeout := sup.ReadyTimeout
+	if timeout <= 0 {
+		timeout = 10 * time.Second
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), timeout)
+	defer cancel()
+
+	if watcher != nil {
+		select {
+		case <-watcher.found:
+		case err := <-exited:
+			// Put the result back for the caller, the buffer is free again
+			exited <- err
+			return fmt.Errorf("exited before ready: %v", err)
+		case <-ctx.Done():
+			return ErrNotReady
+		}
+	}
+	if sup.Ready == nil {
+		return nil
+	}
+	for {
+		err := sup.Ready(ctx)
+		if err == nil {
+			return nil
+		}
+		select {
+		case <-ctx.Done():
+			return fmt.Errorf("%w: %v", ErrNotReady, err)
+		case <-time.After(10 * time.Millisecond):
+		}
+	}
+}
+
+func (sup *Supervisor) supervise(cmd *exec.Cmd, exited <-chan error) {
+	defer close(sup.done)
+	for {
+		started := time.Now()
+		select {
+		case <-sup.stop:
+			sup.terminate(cmd, exited)
+			sup.setState(StateStopped, nil)
+			return
+		case err := <-exited:
+			if err == nil && sup.Policy.OnlyOnFailure {
+				sup.setState(StateStopped, nil)
+				return
+			}
+			if err == nil {
+				err = errors.New("exited")
+			}
+			sup.setState(StateCrashed, err)
+		}
+
+		sup.mu.Lock()
+		if sup.Policy.ResetAfter > 0 && time.Since(started) >= sup.Policy.ResetAfter {
+			sup.restarts = 0
+		}
+		sup.mu.Unlock()
+
+		var err error
+		for {
+			sup.mu.Lock()
+			restarts := sup.restarts
+			sup.mu.Unlock()
+			if sup.Policy.MaxRestarts >= 0 && restarts >= sup.Policy.MaxRestarts {
+				// Budget used up, the process stays crashed
+				return
+			}
+			select {
+			case <-sup.stop:
+				sup.setState(StateStopped, nil)
+				return
+			case <-time.After(sup.Policy.backoff(restarts)):
+			}
+
+			sup.mu.Lock()
+			sup.restarts++
+			sup.mu.Unlock()
+			cmd, exited, err = sup.launch()
+			if err == nil {
+				break
+			}
+			sup.setState(StateCrashed, err)
+		}
+	}
+}
+
+// terminate interrupts the process and kills it if it didn't exit in time.
+func (sup *Supervisor) terminate(cmd *exec.Cmd, exited <-chan error) {
+	timeout := sup.StopTimeout
+	if timeout <= 0 {
+		timeout = time.Second
+	}
+	if err := cmd.Process.Signal(os.Interrupt); err != nil {
+		cmd.Process.Kill()
+	}
+	select {
+	case <-exited:
+	case <-time.After(timeout):
+		cmd.Process.Kill()
+		<-exited
+	}
+}
+
+// Stop stops the process and waits until it exited. A supervisor which gave
+// up restarting is marked as stopped.
+func (sup *Supervisor) Stop() {
+	sup.mu.Lock()
+	done := sup.done
+	if done == nil {
+		sup.mu.Unlock()
+		return
+	}
+	if !sup.stopped {
+		sup.stopped = true
+		close(sup.stop)
+	}
+	sup.mu.Unlock()
+
+	<-done
+	if sup.State() != StateStopped {
+		sup.setState(StateStopped, nil)
+	}
+}
@@ -0,0 +1,172 @@
+package supervisor
+
+import (
+	"context"
+	"io"
+	"os"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/subcommands_test/cli/lib"
+	"github.com/subcommands_test/hub"
+)
+
+// TestHelperProcess isn't a real test. It's started as provider process by the other tests.
+func TestHelperProcess(t *testing.T) {
+	switch os.Getenv("GO_WANT_HELPER_PROCESS") {
+	case "crash":
+		os.Exit(1)
+	case "exit":
+		os.Exit(0)
+	case "cli":
+		provider := &lib.ReaderWriterProvider{
+			Input:       os.Stdin,
+			Output:      os.Stdout,
+			Framed:      true,
+			HandlerFunc: lib.HelloProvider,
+		}
+		done := provider.Start()
+		lib.AnnounceReady(os.Stderr)
+		<-done
+		os.Exit(0)
+	case "silent":
+		<-time.After(time.Minute)
+		os.Exit(0)
+	}
+}
+
+func helper(mode string) *Supervisor {
+	return &Supervisor{
+		Name: mode,
+		Path: os.Args[0],
+		Args: []string{"-test.run=TestHelperProcess"},
+		Env:  append(os.Environ(), "GO_WANT_HELPER_PROCESS="+mode),
+	}
+}
+
+func TestSupervisor_Restart(t *testing.T) {
+	var mu sync.Mutex
+	var states []State
+	sup := helper("crash")
+	sup.Policy = RestartPolicy{
+		MaxRestarts:    2,
+		InitialBackoff: 10 * time.Millisecond,
+	}
+	sup.OnStateChange = func(name string, state State) {
+		mu.Lock()
+		defer mu.Unlock()
+		states = append(states, state)
+	}
+	if err := sup.Start(); err != nil {
+		t.Fatal(err)
+	}
+
+	select {
+	case <-sup.Done():
+	case <-time.After(5 * time.Second):
+		t.Fatal("supervisor didn't give up")
+	}
+	if sup.State() != StateCrashed || sup.Restarts() != 2 || sup.Err() == nil {
+		t.Errorf("invalid state %s after %d restarts: %v", sup.State(), sup.Restarts(), sup.Err())
+	}
+	mu.Lock()
+	ready := 0
+	for _, state := range states {
+		if state == StateReady {
+			ready++
+		}
+	}
+	mu.Unlock()
+	if ready != 3 {
+		t.Errorf("expected 3 starts, got %d: %v", ready, states)
+	}
+
+	sup.Stop()
+	if sup.State() != StateStopped {
+		t.Errorf("expected stopped, got %s", sup.State())
+	}
+}
+
+func TestSupervisor_CleanExit(t *testing.T) {
+	for _, onlyOnFailure := range []bool{true, false} {
+		sup := helper("exit")
+		sup.Policy = RestartPolicy{
+			MaxRestarts:    1,
+			InitialBackoff: 10 * time.Millisecond,
+			OnlyOnFailure:  onlyOnFailure,
+		}
+		if err := sup.Start(); err != nil {
+			t.Fatal(err)
+		}
+		select {
+		case <-sup.Done():
+		case <-time.After(5 * time.Second):
+			t.Fatal("supervisor didn't stop")
+		}
+		if onlyOnFailure && (sup.State() != StateStopped || sup.Restarts() != 0) {
+			t.Errorf("expected clean exit to stop, got %s after %d restarts", sup.State(), sup.Restarts())
+		}
+		if !onlyOnFailure && (sup.State() != StateCrashed || sup.Restarts() != 1) {
+			t.Errorf("expected clean exit to be restarted, got %s after %d restarts", sup.State(), sup.Restarts())
+		}
+		sup.Stop()
+	}
+}
+
+func TestSupervisor_Connect(t *testing.T) {
+	var prov *hub.FramedCliProvider
+	sup := helper("cli")
+	sup.ReadyLine = lib.ReadyLine
+	sup.Connect = func(stdin io.WriteCloser, stdout io.ReadCloser) error {
+		prov = hub.NewFramedCliProvider(stdin, stdout)
+		return nil
+	}
+	sup.Ready = func(ctx context.Context) error {
+		return prov.Health(ctx)
+	}
+	if err := sup.Start(); err != nil {
+		t.Fatal(err)
+	}
+	if sup.State() != StateReady {
+		t.Errorf("expected ready, got %s", sup.State())
+	}
+
+	result, err := prov.Invoke(context.Background(), "", []string{"Kevin"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if result != "Hello, Kevin!" {
+		t.Errorf("invalid result '%s'", result)
+	}
+
+	sup.Stop()
+	if sup.State() != StateStopped {
+		t.Errorf("expected stopped, got %s", sup.State())
+	}
+	if err := sup.Start(); err != ErrStarted {
+		t.Errorf("expected ErrStarted, got %v", err)
+	}
+}
+
+func TestSupervisor_NotReady(t *testing.T) {
+	sup := helper("silent")
+	sup.ReadyLine = lib.ReadyLine
+	sup.ReadyTimeout = 50 * time.Millisecond
+	err := sup.Start()
+	if err != ErrNotReady {
+		t.Errorf("expected ErrNotReady, got %v", err)
+	}
+	if sup.State() != StateCrashed {
+		t.Errorf("expected crashed, got %s", sup.State())
+	}
+}
+
+func TestRestartPolicy_Backoff(t *testing.T) {
+	policy := RestartPolicy{InitialBackoff: time.Second, MaxBackoff: 5 * time.Second}
+	for restarts, expected := range []time.Duration{time.Second, 2 * time.Second, 4 * time.Second, 5 * time.Second, 5 * time.Second} {
+		if backoff := policy.backoff(restarts); backoff != expected {
+			t.Errorf("%d restarts: expected %s, got %s", restarts, expected, backoff)
+		}
+	}
+}
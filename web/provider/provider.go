@@ -0,0 +1,216 @@
+package provider
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strings"
+
+	"github.com/subcommands_test/cli/lib"
+)
+
+// maxRequestBytes limits the JSON body of POST invocations.
+const maxRequestBytes = 1 << 20
+
+// Request is the JSON body of POST invocations. The command is named by
+// Command or, if empty, by the path. The invocation falls back to the
+// X-Sender-Id, X-Channel, ... headers if not set.
+type Request struct {
+	Command    string          `json:"command,omitempty"`
+	Args       []string        `json:"args"`
+	Invocation *lib.Invocation `json:"invocation,omitempty"`
+}
+
+// ErrorBody is sent for failed invocations to clients accepting
+// 'application/json', with the http status of the error code.
+type ErrorBody struct {
+	Error *lib.Error `json:"error"`
+}
+
+// CommandHandler is an http.Handler handling invocations with its handler,
+// like lib.ReaderWriterProvider does for the cli transport.
+// The handler is chosen like lib.Handlers does, lib.HelloProvider is used
+// without any. A *lib.Registry routing the invocations by command name is
+// set as RichHandler. Manifest is served at '/manifest' and '/healthz'
+// answers 'ok' until the handler is drained.
+//
+// All other paths name the command, which is passed as first argument.
+// The arguments are either sent as JSON Request with POST or with GET as
+// 'params' query value split by lib.SplitArgs, e.g. '/echo?params=a b',
+// or as repeated 'args' query values. The response is negotiated by the
+// Accept header: clients accepting 'application/x-ndjson' get chunks as
+// frames as soon as they're sent, followed by the response frame. Clients
+// accepting 'application/json' get the lib.Response with chunks collected
+// or an ErrorBody, all others its text.
+//
+// After Drain invocations are refused, so the ones in flight can finish
+// while the http server shuts down. Serve takes care of that.
+type CommandHandler struct {
+	Manifest *lib.Manifest
+
+	Handler         lib.Command
+	HandlerFunc     lib.CommandFunc
+	FallibleHandler lib.FallibleCommand
+	ContextHandler  lib.ContextCommand
+	RichHandler     lib.RichCommand
+
+	drainer lib.Drainer
+}
+
+// Drain refuses all following invocations and lets health checks fail.
+func (prov *CommandHandler) Drain() {
+	prov.drainer.Drain()
+}
+
+// InFlight returns the number of invocations currently handled.
+func (prov *CommandHandler) InFlight() int {
+	return prov.drainer.InFlight()
+}
+
+// command returns the command handling all invocations.
+func (prov *CommandHandler) command() lib.RichCommand {
+	return lib.Handlers{
+		Handler:         prov.Handler,
+		HandlerFunc:     prov.HandlerFunc,
+		FallibleHandler: prov.FallibleHandler,
+		ContextHandler:  prov.ContextHandler,
+		RichHandler:     prov.RichHandler,
+	}.Command(lib.Rich(lib.WithContext(lib.CommandFunc(lib.HelloProvider))))
+}
+
+func (prov *CommandHandler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	switch r.URL.Path {
+	case "/healthz":
+		prov.health(w, r)
+	case "/manifest":
+		prov.describe(w, r)
+	default:
+		prov.invoke(w, r)
+	}
+}
+
+func (prov *CommandHandler) health(w http.ResponseWriter, r *http.Request) {
+	if prov.drainer.Draining() {
+		http.Error(w, "shutting down", http.StatusServiceUnavailable)
+		return
+	}
+	fmt.Fprint(w, "ok")
+}
+
+func (prov *CommandHandler) describe(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+	if prov.Manifest == nil {
+		http.NotFound(w, r)
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(prov.Manifest)
+}
+
+func (prov *CommandHandler) invoke(w http.ResponseWriter, r *http.Request) {
+	accept := r.Header.Get("Accept")
+	// Set as soon as the first chunk is written, errors are written as frame then
+	streaming := false
+	resp, err := func() (*lib.Response, error) {
+		req, err := ReadRequest(r)
+		if err != nil {
+			return nil, err
+		}
+		if err := prov.drainer.Begin(); err != nil {
+			return nil, err
+		}
+		defer prov.drainer.End()
+
+		ctx := r.Context()
+		if req.Invocation != nil {
+			ctx = lib.WithInvocation(ctx, req.Invocation)
+		}
+		args := req.Args
+		if req.Command != "" {
+			args = append([]string{req.Command}, args...)
+		}
+		cmd := prov.command()
+		if strings.Contains(accept, "application/x-ndjson") {
+			ctx = lib.WithChunks(ctx, func(chunk *lib.Response) error {
+				if !streaming {
+					w.Header().Set("Content-Type", "application/x-ndjson")
+					streaming = true
+				}
+				frame := lib.NewResponseFrame(0, chunk, nil)
+				frame.Type = lib.FrameChunk
+				if err := lib.WriteFrame(w, frame); err != nil {
+					return err
+				}
+				if flusher, ok := w.(http.Flusher); ok {
+					flusher.Flush()
+				}
+				return nil
+			})
+		} else {
+			cmd = lib.Collect(cmd)
+		}
+		return cmd.HandleRich(ctx, args)
+	}()
+	if err == nil && resp == nil {
+		resp = lib.NoReply()
+	}
+
+	switch {
+	case streaming:
+		lib.WriteFrame(w, lib.NewResponseFrame(0, resp, err))
+	case err != nil && strings.Contains(accept, "json"):
+		cmdErr := lib.AsError(err)
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(cmdErr.Code.HTTPStatus())
+		json.NewEncoder(w).Encode(&ErrorBody{Error: cmdErr})
+	case err != nil:
+		cmdErr := lib.AsError(err)
+		http.Error(w, cmdErr.Message, cmdErr.Code.HTTPStatus())
+	case strings.Contains(accept, "json"):
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode(resp)
+	default:
+		fmt.Fprint(w, resp)
+	}
+}
+
+// ReadRequest reads the invocation of the http request as described by
+// CommandHandler. Malformed requests fail with lib.CodeInvalidArgument.
+func ReadRequest(r *http.Request) (*Request, error) {
+	var req Request
+	switch r.Method {
+	case http.MethodGet:
+		query := r.URL.Query()
+		if args, ok := query["args"]; ok {
+			req.Args = args
+		} else {
+			args, err := lib.SplitArgs(query.Get("params"))
+			if err != nil {
+				return nil, lib.Errorf(lib.CodeInvalidArgument, "invalid params: %v", err)
+			}
+			req.Args = args
+		}
+	case http.MethodPost:
+		if contentType := r.Header.Get("Content-Type"); !strings.HasPrefix(contentType, "application/json") {
+			return nil, lib.Errorf(lib.CodeInvalidArgument, "unsupported content type '%s'", contentType)
+		}
+		err := json.NewDecoder(http.MaxBytesReader(nil, r.Body, maxRequestBytes)).Decode(&req)
+		if err != nil {
+			return nil, lib.Errorf(lib.CodeInvalidArgument, "invalid request: %v", err)
+		}
+	default:
+		return nil, lib.Errorf(lib.CodeInvalidArgument, "method %s not allowed", r.Method)
+	}
+	if req.Command == "" {
+		req.Command = strings.Trim(r.URL.Path, "/")
+	}
+	if req.Invocation == nil {
+		if inv, ok := lib.InvocationFromHeader(r.Header); ok {
+			req.Invocation = inv
+		}
+	}
+	return &req, nil
+}
@@ -0,0 +1,181 @@
+package provider
+
+import (
+	"context"
+	"encoding/json"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"testing"
+
+	"github.com/subcommands_test/cli/lib"
+	"github.com/subcommands_test/hub"
+)
+
+// greeter greets the first argument after the command, fails without one
+// and counts down in chunks for 'countdown'.
+var greeter = lib.RichCommandFunc(func(ctx context.Context, args []string) (*lib.Response, error) {
+	if len(args) > 0 && args[0] == "countdown" {
+		for _, n := range []string{"3", "2", "1"} {
+			if err := lib.SendChunk(ctx, lib.Text(n)); err != nil {
+				return nil, err
+			}
+		}
+		return lib.Text("go"), nil
+	}
+	if len(args) < 2 {
+		return nil, lib.Errorf(lib.CodeInvalidArgument, "name missing")
+	}
+	greeting := "Hi " + args[1]
+	if inv, ok := lib.InvocationFromContext(ctx); ok {
+		greeting += " in " + inv.Channel
+	}
+	return lib.Text(greeting), nil
+})
+
+func do(t *testing.T, handler http.Handler, req *http.Request) *http.Response {
+	t.Helper()
+	rec := httptest.NewRecorder()
+	handler.ServeHTTP(rec, req)
+	return rec.Result()
+}
+
+func readBody(t *testing.T, resp *http.Response) string {
+	t.Helper()
+	body, err := ioutil.ReadAll(resp.Body)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return strings.TrimSpace(string(body))
+}
+
+func TestCommandHandler(t *testing.T) {
+	handler := &CommandHandler{RichHandler: greeter}
+
+	t.Run("json", func(t *testing.T) {
+		body := `{"command":"hello","args":["Mary Ann"],"invocation":{"channel":"#general"}}`
+		req := httptest.NewRequest(http.MethodPost, "/", strings.NewReader(body))
+		req.Header.Set("Content-Type", "application/json")
+		req.Header.Set("Accept", "application/json")
+		resp := do(t, handler, req)
+		if resp.StatusCode != http.StatusOK {
+			t.Fatalf("unexpected status %s", resp.Status)
+		}
+		var response lib.Response
+		if err := json.NewDecoder(resp.Body).Decode(&response); err != nil {
+			t.Fatal(err)
+		}
+		if response.String() != "Hi Mary Ann in #general" {
+			t.Errorf("invalid response '%s'", response.String())
+		}
+	})
+	t.Run("params", func(t *testing.T) {
+		req := httptest.NewRequest(http.MethodGet, "/hello?params="+url.QueryEscape(`"Mary Ann"`), nil)
+		req.Header.Set(lib.HeaderChannel, "#general")
+		if body := readBody(t, do(t, handler, req)); body != "Hi Mary Ann in #general" {
+			t.Errorf("invalid body '%s'", body)
+		}
+	})
+	t.Run("args", func(t *testing.T) {
+		req := httptest.NewRequest(http.MethodGet, "/hello?args="+url.QueryEscape("Mary Ann"), nil)
+		if body := readBody(t, do(t, handler, req)); body != "Hi Mary Ann" {
+			t.Errorf("invalid body '%s'", body)
+		}
+	})
+	t.Run("errors", func(t *testing.T) {
+		req := httptest.NewRequest(http.MethodGet, "/hello", nil)
+		resp := do(t, handler, req)
+		if body := readBody(t, resp); resp.StatusCode != http.StatusBadRequest || body != "name missing" {
+			t.Errorf("unexpected text error %s '%s'", resp.Status, body)
+		}
+
+		req = httptest.NewRequest(http.MethodPost, "/hello", strings.NewReader(`{"args":`))
+		req.Header.Set("Content-Type", "application/json")
+		req.Header.Set("Accept", "application/json")
+		resp = do(t, handler, req)
+		var errBody ErrorBody
+		if err := json.NewDecoder(resp.Body).Decode(&errBody); err != nil {
+			t.Fatal(err)
+		}
+		if resp.StatusCode != http.StatusBadRequest || errBody.Error == nil || errBody.Error.Code != lib.CodeInvalidArgument {
+			t.Errorf("unexpected json error %s %+v", resp.Status, errBody.Error)
+		}
+	})
+	t.Run("ndjson", func(t *testing.T) {
+		req := httptest.NewRequest(http.MethodGet, "/countdown", nil)
+		req.Header.Set("Accept", "application/x-ndjson")
+		resp := do(t, handler, req)
+		lines := strings.Split(readBody(t, resp), "\n")
+		if len(lines) != 4 {
+			t.Fatalf("expected 3 chunks and the response, got %q", lines)
+		}
+		frame, err := lib.ParseFrame([]byte(lines[3]))
+		if err != nil {
+			t.Fatal(err)
+		}
+		if frame.Type != "" || frame.Result != "go" {
+			t.Errorf("invalid response frame %+v", frame)
+		}
+	})
+}
+
+func TestCommandHandler_Hub(t *testing.T) {
+	srv := httptest.NewServer(&CommandHandler{
+		Manifest:    lib.NewManifest("webprov", lib.Version, lib.HelloInfo),
+		RichHandler: greeter,
+	})
+	defer srv.Close()
+	prov := hub.NewWebProvider(srv.URL)
+
+	result, err := prov.Invoke(context.Background(), "hello", []string{"Mary Ann"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if result != "Hi Mary Ann" {
+		t.Errorf("invalid result '%s'", result)
+	}
+	var chunks []string
+	resp, err := prov.InvokeStream(context.Background(), "countdown", nil, func(chunk *lib.Response) {
+		chunks = append(chunks, chunk.String())
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if strings.Join(chunks, " ") != "3 2 1" || resp.String() != "go" {
+		t.Errorf("invalid stream %q %s", chunks, resp)
+	}
+	_, err = hub.InvokeRich(context.Background(), prov, "hello", nil)
+	if cmdErr := lib.AsError(err); cmdErr.Code != lib.CodeInvalidArgument || cmdErr.Message != "name missing" {
+		t.Errorf("expected invalid argument error, got %v", err)
+	}
+	manifest, err := prov.Describe(context.Background())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if manifest.Name != "webprov" {
+		t.Errorf("invalid manifest %+v", manifest)
+	}
+}
+
+func TestCommandHandler_Drain(t *testing.T) {
+	handler := &CommandHandler{}
+	resp := do(t, handler, httptest.NewRequest(http.MethodGet, "/healthz", nil))
+	if resp.StatusCode != http.StatusOK {
+		t.Errorf("unexpected health %s", resp.Status)
+	}
+	if body := readBody(t, do(t, handler, httptest.NewRequest(http.MethodGet, "/?params=Kevin", nil))); body != lib.HelloProvider([]string{"Kevin"}) {
+		t.Errorf("invalid default greeting '%s'", body)
+	}
+
+	handler.Drain()
+	resp = do(t, handler, httptest.NewRequest(http.MethodGet, "/healthz", nil))
+	if resp.StatusCode != http.StatusServiceUnavailable {
+		t.Errorf("expected failing health after drain, got %s", resp.Status)
+	}
+	resp = do(t, handler, httptest.NewRequest(http.MethodGet, "/?params=Kevin", nil))
+	if resp.StatusCode != http.StatusServiceUnavailable {
+		t.Errorf("expected refused invocation after drain, got %s", resp.Status)
+	}
+}
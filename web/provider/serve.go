@@ -0,0 +1,128 @@
+package provider
+
+import (
+	"context"
+	"errors"
+	"log"
+	"net/http"
+	"os"
+	"os/signal"
+	"syscall"
+	"time"
+
+	"github.com/subcommands_test/cli/lib"
+	"golang.org/x/net/http2"
+	"golang.org/x/net/http2/h2c"
+)
+
+// Options configure how Serve listens and shuts down.
+type Options struct {
+	// Network is either 'tcp' or 'unix'. Defaults to 'tcp'.
+	Network string
+	// Address to listen on, the path of the socket for 'unix'. Defaults
+	// to ':8080'.
+	Address string
+	// GracePeriod is the time in-flight invocations have to finish on
+	// shutdown. Defaults to 5s.
+	GracePeriod time.Duration
+	// Signals stopping the server. Defaults to SIGINT and SIGTERM.
+	Signals []os.Signal
+	// H2C accepts cleartext HTTP/2 with prior knowledge besides HTTP/1.1.
+	H2C bool
+}
+
+func (opts *Options) defaults() {
+	if opts.Network == "" {
+		opts.Network = "tcp"
+	}
+	if opts.Address == "" {
+		opts.Address = ":8080"
+	}
+	if opts.GracePeriod <= 0 {
+		opts.GracePeriod = 5 * time.Second
+	}
+	if len(opts.Signals) == 0 {
+		opts.Signals = []os.Signal{os.Interrupt, syscall.SIGTERM}
+	}
+}
+
+// Serve listens on the address of opts and serves the handler until a
+// signal is received or ctx is done. It announces that it's ready with
+// lib.AnnounceReady once it's listening. Unix sockets are listened on with
+// lib.Listen, so stale ones are replaced and they're removed on shutdown.
+//
+// On shutdown the handler is drained, so '/healthz' fails and new
+// invocations are refused, and the server stops accepting connections.
+// In-flight invocations get the grace period to finish before they're
+// abandoned.
+func Serve(ctx context.Context, handler *CommandHandler, opts Options) error {
+	opts.defaults()
+	lis, err := lib.Listen(opts.Network, opts.Address)
+	if err != nil {
+		return err
+	}
+	defer lis.Close()
+	srv := &http.Server{Handler: handler}
+	if opts.H2C {
+		srv.Handler = h2c.NewHandler(handler, &http2.Server{})
+	}
+
+	served := make(chan error, 1)
+	go func() {
+		served <- srv.Serve(lis)
+	}()
+	lib.AnnounceReady(os.Stderr)
+
+	sigs := make(chan os.Signal, 1)
+	signal.Notify(sigs, opts.Signals...)
+	defer signal.Stop(sigs)
+
+	select {
+	case err := <-served:
+		// Server has been closed for any reason
+		return err
+	case sig := <-sigs:
+		log.Printf("Signal received: %v\n", sig)
+	case <-ctx.Done():
+	}
+
+	handler.Drain()
+	shutdownCtx, cancel := context.WithTimeout(context.Background(), opts.GracePeriod)
+	defer cancel()
+	err = srv.Shutdown(shutdownCtx)
+	if err != nil && err != context.DeadlineExceeded {
+		return err
+	}
+	if abandoned := waitIdle(shutdownCtx, handler); err != nil || abandoned > 0 {
+		srv.Close()
+		log.Printf("Grace period exceeded, abandoned %d invocations\n", abandoned)
+	}
+	select {
+	case err := <-served:
+		if err == http.ErrServerClosed {
+			return nil
+		}
+		return err
+	case <-time.After(time.Second):
+		return errors.New("server wasn't closed after shutdown")
+	}
+}
+
+// waitIdle waits until the handler has no invocations in flight or ctx is
+// done and returns how many are left. Shutdown doesn't wait for h2c
+// connections, which are hijacked from the server.
+func waitIdle(ctx context.Context, handler *CommandHandler) int {
+	ticker := time.NewTicker(10 * time.Millisecond)
+	defer ticker.Stop()
+	for {
+		inFlight := handler.InFlight()
+		if inFlight == 0 {
+			return 0
+		}
+		select {
+		case <-ctx.Done():
+			return inFlight
+		case <-ticker.C:
+		}
+	}
+}
@@ -0,0 +1,66 @@
+package provider
+
+import (
+	"context"
+	"io/ioutil"
+	"net"
+	"net/http"
+	"testing"
+	"time"
+
+	"github.com/subcommands_test/cli/lib"
+)
+
+func TestServe(t *testing.T) {
+	handler := &CommandHandler{
+		FallibleHandler: lib.FallibleCommandFunc(func(args []string) (string, error) {
+			if len(args) == 0 {
+				return "", lib.Errorf(lib.CodeInvalidArgument, "name missing")
+			}
+			return "Hi " + args[0], nil
+		}),
+	}
+	// Reserve a free port for the server
+	lis, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	address := lis.Addr().String()
+	lis.Close()
+
+	ctx, cancel := context.WithCancel(context.Background())
+	served := make(chan error, 1)
+	go func() {
+		served <- Serve(ctx, handler, Options{Address: address, GracePeriod: time.Second})
+	}()
+
+	var resp *http.Response
+	for i := 0; i < 50; i++ {
+		resp, err = http.Get("http://" + address + "/?params=Kevin")
+		if err == nil {
+			break
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+	if err != nil {
+		t.Fatal(err)
+	}
+	body, err := ioutil.ReadAll(resp.Body)
+	resp.Body.Close()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if string(body) != "Hi Kevin" {
+		t.Errorf("invalid body '%s'", body)
+	}
+
+	cancel()
+	select {
+	case err := <-served:
+		if err != nil {
+			t.Error(err)
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatal("Serve didn't return after ctx was done")
+	}
+}
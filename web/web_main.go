@@ -5,60 +5,52 @@ import (
 	"flag"
 	"fmt"
 	"log"
-	"net/http"
-	"os"
-	"os/signal"
-	"syscall"
 	"time"
+
+	"github.com/subcommands_test/cli/lib"
+	"github.com/subcommands_test/web/provider"
 )
 
 func main() {
-	port := flag.Int("port", 8080, "Port to listen on")
+	network := flag.String("network", "tcp", "Network to use. Either 'tcp' or 'unix'. Default is tcp")
+	address := flag.String("address", "", "Address to listen to, e.g. '/tmp/web_subcommand.sock' for unix. Default is ':<port>'")
+	port := flag.Int("port", 8080, "Port to listen on if -address isn't set")
+	multi := flag.Bool("multi", false, "Provide all commands of the lib package instead of hello only")
+	gracePeriod := flag.Duration("grace-period", 5*time.Second, "Time in-flight invocations have to finish on shutdown")
+	announce := flag.Duration("announce", 0, "Push an announcement event to -events-url in this interval")
+	eventsURL := flag.String("events-url", "", "Webhook of the hub events are pushed to, e.g. 'http://localhost:8000/events/webprov'")
+	eventsToken := flag.String("events-token", "", "Token authenticating the provider at the webhook")
+	h2c := flag.Bool("h2c", false, "Accept cleartext HTTP/2 with prior knowledge besides HTTP/1.1")
 
 	flag.Parse()
 
-	srv := http.Server{Addr: fmt.Sprintf(":%d", *port)}
-
-	http.HandleFunc("/", func(w http.ResponseWriter, r *http.Request) {
-		fmt.Fprintf(w, "Hello, %s!", r.URL.Query().Get("params"))
-	})
-
-	waitc := make(chan struct{})
-	go func() {
-		defer close(waitc)
-		err := srv.ListenAndServe()
-		if err == http.ErrServerClosed {
-			return
-		}
-		if err != nil {
-			log.Println(err)
+	handler := &provider.CommandHandler{
+		Manifest:    lib.NewManifest("webprov", lib.Version, lib.HelloInfo),
+		HandlerFunc: lib.HelloProvider,
+	}
+	if *multi {
+		reg := lib.DefaultRegistry()
+		handler.RichHandler = reg
+		handler.Manifest.Commands = reg.Describe()
+	}
+	if *eventsURL != "" && *announce > 0 {
+		webhook := &lib.EventWebhook{
+			URL:   *eventsURL,
+			Token: *eventsToken,
 		}
-	}()
-
-	sigs := make(chan os.Signal)
-	waitsig := make(chan struct{})
-
-	signal.Notify(sigs, os.Interrupt, syscall.SIGINT, syscall.SIGTERM, syscall.SIGKILL)
-
-	go func() {
-		sig := <-sigs
-		log.Printf("Signal received: %v\n", sig)
-		close(waitsig)
-	}()
+		go lib.Announce(context.Background(), *announce, "webprov is still running", webhook.Push)
+	}
 
-	select {
-	case <-waitc:
-		// Server has been closed for any reason
-	case <-waitsig:
-		// Signal received, server has to be closed now
-		err := srv.Shutdown(context.Background())
-		if err != nil {
-			log.Fatalf("failed to shutdown server: %v", err)
-		}
-		select {
-		case <-waitc:
-		case <-time.After(time.Second):
-			log.Fatal("server wasn't closed after shutdown")
-		}
+	if *address == "" {
+		*address = fmt.Sprintf(":%d", *port)
+	}
+	err := provider.Serve(context.Background(), handler, provider.Options{
+		Network:     *network,
+		Address:     *address,
+		GracePeriod: *gracePeriod,
+		H2C:         *h2c,
+	})
+	if err != nil {
+		log.Fatal(err)
 	}
 }